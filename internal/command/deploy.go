@@ -0,0 +1,175 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/score-spec/score-implementation-avassa/internal/controltower"
+	"github.com/score-spec/score-implementation-avassa/internal/state"
+)
+
+const (
+	apiCmdURLFlag      = "url"
+	apiCmdCACertFlag   = "ca-cert"
+	apiCmdInsecureFlag = "insecure-skip-tls-verify"
+
+	deployCmdDryRunFlag          = "dry-run"
+	deployCmdMatchSiteLabelsFlag = "match-site-labels"
+	deployCmdStrictFlag          = "strict"
+)
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy [workload...]",
+	Short: "Create or update the generated applications in Avassa Control Tower",
+	Long: `Convert the workloads in the project state (all of them, or the named ones) and create or update the
+applications through the Control Tower REST API. With --match-site-labels an application deployment named
+<app>-deployment is created or updated for each application as well.
+
+Credentials are read from AVASSA_USERNAME and AVASSA_PASSWORD, or from AVASSA_ROLE_ID and AVASSA_SECRET_ID for an
+approle. AVASSA_URL and AVASSA_CA_CERT are used when --url and --ca-cert are not set.`,
+	Args: cobra.ArbitraryArgs,
+	CompletionOptions: cobra.CompletionOptions{
+		HiddenDefaultCmd: true,
+	},
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		sd, ok, err := state.LoadStateDirectory(".")
+		if err != nil {
+			return fmt.Errorf("failed to load existing state directory: %w", err)
+		} else if !ok {
+			return fmt.Errorf("state directory does not exist, please run \"init\" first")
+		}
+		workloadNames, err := selectWorkloads(&sd.State, args)
+		if err != nil {
+			return err
+		}
+		strict, _ := cmd.Flags().GetBool(deployCmdStrictFlag)
+		apps, err := convertWorkloads(cmd, &sd.State, sd.Config, workloadNames, strict)
+		if err != nil {
+			return err
+		}
+		matchSiteLabels, _ := cmd.Flags().GetString(deployCmdMatchSiteLabelsFlag)
+
+		if dryRun, _ := cmd.Flags().GetBool(deployCmdDryRunFlag); dryRun {
+			for _, app := range apps {
+				name := app.Name
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "dry-run: PUT %s\n", controltower.ApplicationPath(name))
+				if matchSiteLabels != "" {
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "dry-run: PUT %s\n", controltower.ApplicationDeploymentPath(deploymentName(name)))
+				}
+			}
+			return nil
+		}
+
+		client, err := newAPIClient(cmd)
+		if err != nil {
+			return err
+		}
+		if err := client.Login(cmd.Context()); err != nil {
+			return err
+		}
+		for _, app := range apps {
+			name := app.Name
+			created, err := client.PutApplication(cmd.Context(), app.Manifest)
+			if err != nil {
+				return fmt.Errorf("failed to deploy: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "application '%s' %s\n", name, createdOrUpdated(created))
+			if matchSiteLabels == "" {
+				continue
+			}
+			deployment := map[string]interface{}{
+				"name":        deploymentName(name),
+				"application": name,
+				"placement":   map[string]interface{}{"match-site-labels": matchSiteLabels},
+			}
+			if app.Version != "" {
+				deployment["application-version"] = app.Version
+			}
+			if created, err = client.PutApplicationDeployment(cmd.Context(), deployment); err != nil {
+				return fmt.Errorf("failed to deploy: %w", err)
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "application-deployment '%s' %s\n", deploymentName(name), createdOrUpdated(created))
+		}
+		return nil
+	},
+}
+
+// deploymentName is the name of the application deployment created for an application.
+func deploymentName(appName string) string {
+	return appName + "-deployment"
+}
+
+func createdOrUpdated(created bool) string {
+	if created {
+		return "created"
+	}
+	return "updated"
+}
+
+// selectWorkloads returns the named workloads in the given order without repeats, or all workloads in the state sorted
+// by name when no names are given.
+func selectWorkloads(currentState *state.State, names []string) ([]string, error) {
+	if len(currentState.Workloads) == 0 {
+		return nil, fmt.Errorf("project is empty, please add a score file with \"generate\"")
+	}
+	if len(names) == 0 {
+		return slices.Sorted(maps.Keys(currentState.Workloads)), nil
+	}
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := currentState.Workloads[name]; !ok {
+			return nil, fmt.Errorf("workload '%s' is not in the project", name)
+		} else if !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// addAPIFlags adds the Control Tower connection flags shared by the commands that talk to the API.
+func addAPIFlags(cmd *cobra.Command) {
+	cmd.Flags().String(apiCmdURLFlag, "", "The Control Tower API url, defaults to "+controltower.EnvURL)
+	cmd.Flags().String(apiCmdCACertFlag, "", "A PEM file of certificate authorities to trust for the API, defaults to "+controltower.EnvCACert)
+	cmd.Flags().Bool(apiCmdInsecureFlag, false, "Skip verification of the API server certificate")
+}
+
+// newAPIClient builds a Control Tower client from the environment and the connection flags.
+func newAPIClient(cmd *cobra.Command) (*controltower.Client, error) {
+	config := controltower.ConfigFromEnv()
+	if v, _ := cmd.Flags().GetString(apiCmdURLFlag); v != "" {
+		config.URL = v
+	}
+	if v, _ := cmd.Flags().GetString(apiCmdCACertFlag); v != "" {
+		config.CACertFile = v
+	}
+	config.InsecureSkipVerify, _ = cmd.Flags().GetBool(apiCmdInsecureFlag)
+	return controltower.NewClient(config)
+}
+
+func init() {
+	addAPIFlags(deployCmd)
+	deployCmd.Flags().Bool(deployCmdDryRunFlag, false, "Print the API requests that would be made without contacting Control Tower")
+	deployCmd.Flags().String(deployCmdMatchSiteLabelsFlag, "", "Also create or update an application deployment placed on the sites matching this label expression")
+	deployCmd.Flags().Bool(deployCmdStrictFlag, false, "Fail instead of warning when parts of a Score file cannot be converted")
+	rootCmd.AddCommand(deployCmd)
+}
@@ -0,0 +1,205 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/score-spec/score-implementation-avassa/internal/controltower"
+)
+
+// fakeControlTower is an in-process stand-in for the Control Tower REST API that stores the objects it is sent.
+type fakeControlTower struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string]map[string]interface{}
+	// state holds the responses of the /v1/state api by path
+	state map[string]interface{}
+}
+
+func newFakeControlTower(t *testing.T) *fakeControlTower {
+	t.Helper()
+	f := &fakeControlTower{objects: map[string]map[string]interface{}{}, state: map[string]interface{}{}}
+	f.Server = httptest.NewTLSServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeControlTower) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/login":
+		if body["username"] != "admin" || body["password"] != "secret" {
+			http.Error(w, `{"errors": [{"error-message": "invalid credentials"}]}`, http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token": "user-token"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/approle-login":
+		if body["role-id"] != "role" || body["secret-id"] != "s3cret" {
+			http.Error(w, `{"errors": [{"error-message": "invalid approle"}]}`, http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token": "approle-token"}`))
+	case r.Header.Get("Authorization") != "Bearer user-token" && r.Header.Get("Authorization") != "Bearer approle-token":
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	case strings.HasPrefix(r.URL.Path, "/v1/state/"):
+		if v, ok := f.state[r.URL.Path]; ok {
+			_ = json.NewEncoder(w).Encode(v)
+		} else {
+			http.NotFound(w, r)
+		}
+	case r.Method == http.MethodPut:
+		_, exists := f.objects[r.URL.Path]
+		f.objects[r.URL.Path] = body
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case r.Method == http.MethodGet:
+		if v, ok := f.objects[r.URL.Path]; ok {
+			_ = json.NewEncoder(w).Encode(v)
+		} else {
+			http.NotFound(w, r)
+		}
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+// caFile writes the certificate of the fake server to a PEM file and returns its path.
+func (f *fakeControlTower) caFile(t *testing.T) string {
+	t.Helper()
+	path := t.TempDir() + "/ca.pem"
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.Certificate().Raw}), 0644))
+	return path
+}
+
+func TestDeployDryRun(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "score.yaml"})
+	require.NoError(t, err)
+
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--dry-run", "--match-site-labels", "system/type = edge"})
+	require.NoError(t, err)
+	assert.Equal(t, "dry-run: PUT /v1/config/applications/example\ndry-run: PUT /v1/config/application-deployments/example-deployment\n", stdout)
+
+	// a workload named twice is deployed once
+	stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--dry-run", "--match-site-labels", "system/type = edge", "example", "example"})
+	require.NoError(t, err)
+	assert.Equal(t, "dry-run: PUT /v1/config/applications/example\ndry-run: PUT /v1/config/application-deployments/example-deployment\n", stdout)
+}
+
+func TestDeployStrictOnlyCountsSelectedWorkloads(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove("score.yaml"))
+	writeScoreFile(t, "aa.yaml", "aa")
+	require.NoError(t, os.WriteFile("bb.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: bb
+  annotations:
+    avassa.replica: "2"
+containers:
+  main:
+    image: busybox
+`), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "aa.yaml", "bb.yaml"})
+	require.NoError(t, err)
+
+	stdout, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--strict", "--dry-run", "aa"})
+	require.NoError(t, err)
+	assert.Equal(t, "dry-run: PUT /v1/config/applications/aa\n", stdout)
+	assert.Equal(t, "", stderr)
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--strict", "--dry-run", "bb"})
+	assert.EqualError(t, err, "conversion produced 1 warnings in strict mode: workload: bb: metadata.annotations.avassa.replica: unknown annotation, the annotation was ignored")
+}
+
+func TestDeployCreatesAndUpdates(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--override-property", `metadata.annotations.avassa\.io/version="1.2"`, "score.yaml"})
+	require.NoError(t, err)
+
+	server := newFakeControlTower(t)
+	t.Setenv(controltower.EnvUsername, "admin")
+	t.Setenv(controltower.EnvPassword, "secret")
+	args := []string{"deploy", "--url", server.URL, "--ca-cert", server.caFile(t), "--match-site-labels", "system/type = edge"}
+
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, args)
+	require.NoError(t, err)
+	assert.Equal(t, "application 'example' created\napplication-deployment 'example-deployment' created\n", stdout)
+	assert.Equal(t, "example", server.objects["/v1/config/applications/example"]["name"])
+	assert.Equal(t, map[string]interface{}{
+		"name":                "example-deployment",
+		"application":         "example",
+		"application-version": "1.2",
+		"placement":           map[string]interface{}{"match-site-labels": "system/type = edge"},
+	}, server.objects["/v1/config/application-deployments/example-deployment"])
+
+	stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, args[:5])
+	require.NoError(t, err)
+	assert.Equal(t, "application 'example' updated\n", stdout)
+}
+
+func TestDeployWithApprole(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "score.yaml"})
+	require.NoError(t, err)
+
+	server := newFakeControlTower(t)
+	t.Setenv(controltower.EnvURL, server.URL)
+	t.Setenv(controltower.EnvRoleID, "role")
+	t.Setenv(controltower.EnvSecretID, "wrong")
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--insecure-skip-tls-verify", "example"})
+	assert.ErrorContains(t, err, "failed to log in: POST /v1/approle-login: unexpected status 401")
+
+	t.Setenv(controltower.EnvSecretID, "s3cret")
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--insecure-skip-tls-verify", "example"})
+	require.NoError(t, err)
+	assert.Equal(t, "application 'example' created\n", stdout)
+
+	// without a trusted ca the server certificate is rejected
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "example"})
+	assert.ErrorContains(t, err, "certificate")
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"deploy", "--insecure-skip-tls-verify", "missing"})
+	assert.EqualError(t, err, "workload 'missing' is not in the project")
+}
@@ -0,0 +1,253 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/score-spec/score-implementation-avassa/internal/state"
+)
+
+const (
+	diffCmdFromFileFlag = "from-file"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [workload...]",
+	Short: "Show how the generated applications differ from what is deployed",
+	Long: `Convert the workloads in the project state (all of them, or the named ones) and compare each application with
+the one in Control Tower, or with a previous manifests file when --from-file is set. Key order and the order of named
+list items such as services or containers are ignored. The command fails when any application differs.
+
+The Control Tower connection is configured like the deploy command.`,
+	Args: cobra.ArbitraryArgs,
+	CompletionOptions: cobra.CompletionOptions{
+		HiddenDefaultCmd: true,
+	},
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		sd, ok, err := state.LoadStateDirectory(".")
+		if err != nil {
+			return fmt.Errorf("failed to load existing state directory: %w", err)
+		} else if !ok {
+			return fmt.Errorf("state directory does not exist, please run \"init\" first")
+		}
+		workloadNames, err := selectWorkloads(&sd.State, args)
+		if err != nil {
+			return err
+		}
+		apps, err := convertWorkloads(cmd, &sd.State, sd.Config, workloadNames, false)
+		if err != nil {
+			return err
+		}
+
+		var lookup func(name string) (map[string]interface{}, bool, error)
+		if fromFile, _ := cmd.Flags().GetString(diffCmdFromFileFlag); fromFile != "" {
+			previous, err := readManifestsFile(fromFile)
+			if err != nil {
+				return err
+			}
+			lookup = func(name string) (map[string]interface{}, bool, error) {
+				app, ok := previous[name]
+				return app, ok, nil
+			}
+		} else {
+			client, err := newAPIClient(cmd)
+			if err != nil {
+				return err
+			}
+			if err := client.Login(cmd.Context()); err != nil {
+				return err
+			}
+			lookup = func(name string) (map[string]interface{}, bool, error) {
+				return client.GetApplication(cmd.Context(), name)
+			}
+		}
+
+		differing := 0
+		for _, app := range apps {
+			name := app.Name
+			current, ok, err := lookup(name)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				differing++
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "application '%s' is new\n", name)
+				continue
+			}
+			changes := diffValues("", normaliseJSON(current), normaliseJSON(app.Manifest))
+			if len(changes) == 0 {
+				continue
+			}
+			differing++
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "application '%s':\n", name)
+			for _, change := range changes {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", change)
+			}
+		}
+		if differing > 0 {
+			return fmt.Errorf("%d of %d applications differ", differing, len(apps))
+		}
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "no differences")
+		return nil
+	},
+}
+
+// readManifestsFile reads a manifests file written by generate, in yaml or json, and returns the applications by name.
+func readManifestsFile(path string) (map[string]map[string]interface{}, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests file: %w", err)
+	}
+	out := make(map[string]map[string]interface{})
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	for {
+		var doc interface{}
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode manifests file '%s': %w", path, err)
+		}
+		// a json array holds several applications
+		docs, ok := doc.([]interface{})
+		if !ok {
+			docs = []interface{}{doc}
+		}
+		for _, d := range docs {
+			if app, ok := d.(map[string]interface{}); ok {
+				name, _ := app["name"].(string)
+				out[name] = app
+			}
+		}
+	}
+	return out, nil
+}
+
+// normaliseJSON round-trips the value through json so that numbers and nested types compare equally regardless of
+// where the value was read from.
+func normaliseJSON(v interface{}) interface{} {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// diffValues returns the changes from old to new, one line per changed leaf: "+ path: value" for additions,
+// "- path: value" for removals and "~ path: old -> new" for changes. Lists whose items all have a name are compared
+// by name rather than position.
+func diffValues(path string, old, new interface{}) []string {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		var out []string
+		keys := slices.Collect(maps.Keys(oldMap))
+		for k := range newMap {
+			if _, ok := oldMap[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			childPath := joinDiffPath(path, k)
+			ov, inOld := oldMap[k]
+			nv, inNew := newMap[k]
+			switch {
+			case !inOld:
+				out = append(out, fmt.Sprintf("+ %s: %s", childPath, compactJSON(nv)))
+			case !inNew:
+				out = append(out, fmt.Sprintf("- %s: %s", childPath, compactJSON(ov)))
+			default:
+				out = append(out, diffValues(childPath, ov, nv)...)
+			}
+		}
+		return out
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		oldByName, oldNamed := itemsByName(oldList)
+		newByName, newNamed := itemsByName(newList)
+		if oldNamed && newNamed {
+			return diffValues(path, oldByName, newByName)
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		return []string{fmt.Sprintf("~ %s: %s -> %s", path, compactJSON(old), compactJSON(new))}
+	}
+	return nil
+}
+
+// itemsByName indexes the list items by name as "[name]" keys, if every item is a map with a unique name.
+func itemsByName(list []interface{}) (map[string]interface{}, bool) {
+	out := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		key := "[" + name + "]"
+		if _, exists := out[key]; exists {
+			return nil, false
+		}
+		out[key] = item
+	}
+	return out, true
+}
+
+func joinDiffPath(path, key string) string {
+	if path == "" || strings.HasPrefix(key, "[") {
+		return path + key
+	}
+	return path + "." + key
+}
+
+func compactJSON(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(raw)
+}
+
+func init() {
+	addAPIFlags(diffCmd)
+	diffCmd.Flags().String(diffCmdFromFileFlag, "", "Compare with the applications in this manifests file instead of Control Tower")
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/score-spec/score-implementation-avassa/internal/controltower"
+)
+
+func TestDiffFromFile(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "-o", "previous.json", "--format", "json", "score.yaml"})
+	require.NoError(t, err)
+
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"diff", "--from-file", "previous.json"})
+	require.NoError(t, err)
+	assert.Equal(t, "no differences\n", stdout)
+
+	// reordering keys and containers is not a difference
+	require.NoError(t, os.WriteFile("previous.yaml", []byte(`
+services:
+  - share-pid-namespace: false
+    containers:
+      - shutdown-timeout: 10s
+        mounts: []
+        name: main
+        image: stefanprodan/podinfo
+        container-log-size: 100 MB
+        readiness: dropped
+    replicas: 1
+    name: example-service
+    mode: replicated
+name: example
+`), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+		"generate", "score.yaml",
+		"--override-property", `metadata.annotations.avassa\.replicas="2"`,
+		"--override-property", `metadata.annotations.avassa\.on-mutable-variable-change=none`,
+	})
+	require.NoError(t, err)
+	stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"diff", "--from-file", "previous.yaml"})
+	assert.EqualError(t, err, "1 of 1 applications differ")
+	assert.Equal(t, `application 'example':
+  + on-mutable-variable-change: "none"
+  - services[example-service].containers[main].readiness: "dropped"
+  ~ services[example-service].replicas: 1 -> 2
+`, stdout)
+}
+
+func TestDiffAgainstControlTower(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "score.yaml"})
+	require.NoError(t, err)
+
+	server := newFakeControlTower(t)
+	t.Setenv(controltower.EnvUsername, "admin")
+	t.Setenv(controltower.EnvPassword, "secret")
+	connection := []string{"--url", server.URL, "--ca-cert", server.caFile(t)}
+
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, append([]string{"diff"}, connection...))
+	assert.EqualError(t, err, "1 of 1 applications differ")
+	assert.Equal(t, "application 'example' is new\n", stdout)
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, append([]string{"deploy"}, connection...))
+	require.NoError(t, err)
+	stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, append([]string{"diff"}, connection...))
+	require.NoError(t, err)
+	assert.Equal(t, "no differences\n", stdout)
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "score.yaml", "--image", "example/main=busybox:1.36"})
+	require.NoError(t, err)
+	stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, append([]string{"diff"}, connection...))
+	assert.EqualError(t, err, "1 of 1 applications differ")
+	assert.Equal(t, "application 'example':\n  ~ services[example-service].containers[main].image: \"stefanprodan/podinfo\" -> \"busybox:1.36\"\n", stdout)
+}
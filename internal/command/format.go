@@ -0,0 +1,114 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/score-spec/score-implementation-avassa/pkg/avassa"
+)
+
+const (
+	outputFormatYAML   = "yaml"
+	outputFormatJSON   = "json"
+	outputFormatNDJSON = "ndjson"
+)
+
+// validateOutputFormat checks the --format flag value.
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputFormatYAML, outputFormatJSON, outputFormatNDJSON:
+		return nil
+	}
+	return fmt.Errorf("--%s: unsupported format '%s', expected %s, %s or %s", generateCmdFormatFlag, format, outputFormatYAML, outputFormatJSON, outputFormatNDJSON)
+}
+
+// encodeManifests writes the applications in the given format: yaml documents separated by ---, a json array, or one
+// compact json object per line.
+func encodeManifests(w io.Writer, format string, apps []avassa.Application) error {
+	switch format {
+	case outputFormatYAML:
+		for _, app := range apps {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return fmt.Errorf("failed to write document separator: %w", err)
+			}
+			if err := encodeManifestYAML(w, app); err != nil {
+				return err
+			}
+		}
+	case outputFormatJSON:
+		out := new(bytes.Buffer)
+		out.WriteString("[")
+		for i, app := range apps {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			raw, err := app.MarshalJSON()
+			if err != nil {
+				return fmt.Errorf("failed to encode manifest: %w", err)
+			}
+			out.Write(raw)
+		}
+		out.WriteString("]")
+		return writeIndentedJSON(w, out.Bytes())
+	case outputFormatNDJSON:
+		for _, app := range apps {
+			if err := encodeManifestJSON(w, app, false); err != nil {
+				return err
+			}
+		}
+	default:
+		return validateOutputFormat(format)
+	}
+	return nil
+}
+
+// encodeManifestYAML writes a single application as a yaml document.
+func encodeManifestYAML(w io.Writer, app avassa.Application) error {
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+	if err := enc.Encode(app); err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return nil
+}
+
+// encodeManifestJSON writes a single application as json followed by a newline.
+func encodeManifestJSON(w io.Writer, app avassa.Application, indent bool) error {
+	raw, err := app.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if indent {
+		return writeIndentedJSON(w, raw)
+	}
+	_, err = w.Write(append(raw, '\n'))
+	return err
+}
+
+func writeIndentedJSON(w io.Writer, raw []byte) error {
+	out := new(bytes.Buffer)
+	if err := json.Indent(out, raw, "", "  "); err != nil {
+		return fmt.Errorf("failed to indent json: %w", err)
+	}
+	out.WriteString("\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
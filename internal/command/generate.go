@@ -16,25 +16,22 @@ package command
 
 import (
     "bytes"
+    "errors"
     "fmt"
-    "io"
     "log/slog"
+    "maps"
     "os"
+    "path/filepath"
     "slices"
-    "sort"
     "strings"
 
     "dario.cat/mergo"
     "github.com/score-spec/score-go/framework"
-    scoreloader "github.com/score-spec/score-go/loader"
-    scoreschema "github.com/score-spec/score-go/schema"
-    scoretypes "github.com/score-spec/score-go/types"
     "github.com/spf13/cobra"
     "gopkg.in/yaml.v3"
 
-    "github.com/score-spec/score-implementation-avassa/internal/convert"
-    "github.com/score-spec/score-implementation-avassa/internal/provisioners"
     "github.com/score-spec/score-implementation-avassa/internal/state"
+    "github.com/score-spec/score-implementation-avassa/pkg/avassa"
 )
 
 const (
@@ -43,11 +40,19 @@ const (
     generateCmdImageFlag            = "image"
     generateCmdOutputFlag           = "output"
     generateCmdStdoutFlag           = "stdout"
+    generateCmdStrictFlag           = "strict"
+    generateCmdOutputDirFlag        = "output-dir"
+    generateCmdFormatFlag           = "format"
 )
 
 var generateCmd = &cobra.Command{
-	Use:   "generate",
+	Use:   "generate [score-file...]",
 	Short: "Run the conversion from score file to output manifests",
+	Long: `Add the given Score files to the project and convert all workloads of the project into Avassa applications.
+
+Score files and stdin (-) may hold several workloads as documents separated by ---, files[].source stays relative to
+the file the workload is in. Directories, including the ./services/... form, are searched recursively for score*.yaml
+files, skipping hidden directories. Globs such as 'services/*/score.yaml' are expanded in the same way when the shell has not done so already.`,
 	Args:  cobra.ArbitraryArgs,
 	CompletionOptions: cobra.CompletionOptions{
 		HiddenDefaultCmd: true,
@@ -56,6 +61,20 @@ var generateCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
 
+		format, _ := cmd.Flags().GetString(generateCmdFormatFlag)
+		if err := validateOutputFormat(format); err != nil {
+			return err
+		}
+		dir, _ := cmd.Flags().GetString(generateCmdOutputDirFlag)
+		if dir != "" {
+			if toStdout, _ := cmd.Flags().GetBool(generateCmdStdoutFlag); toStdout {
+				return fmt.Errorf("--%s cannot be combined with --%s", generateCmdOutputDirFlag, generateCmdStdoutFlag)
+			}
+			if cmd.Flags().Changed(generateCmdOutputFlag) {
+				return fmt.Errorf("--%s cannot be combined with --%s", generateCmdOutputDirFlag, generateCmdOutputFlag)
+			}
+		}
+
 		sd, ok, err := state.LoadStateDirectory(".")
 		if err != nil {
 			return fmt.Errorf("failed to load existing state directory: %w", err)
@@ -64,64 +83,79 @@ var generateCmd = &cobra.Command{
 		}
 		currentState := &sd.State
 
-		if len(args) != 1 && (cmd.Flags().Lookup(generateCmdOverridesFileFlag).Changed || cmd.Flags().Lookup(generateCmdOverridePropertyFlag).Changed || cmd.Flags().Lookup(generateCmdImageFlag).Changed) {
-			return fmt.Errorf("cannot use --%s, --%s, or --%s when 0 or more than 1 score files are provided", generateCmdOverridePropertyFlag, generateCmdOverridesFileFlag, generateCmdImageFlag)
+		files, err := expandScoreFileArgs(args)
+		if err != nil {
+			return err
+		}
+		var documents []scoreDocument
+		var workloadNames []string
+		for _, file := range files {
+			fileDocuments, err := readScoreDocuments(file, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			for _, document := range fileDocuments {
+				rawMetadata, _ := document.raw["metadata"].(map[string]interface{})
+				if name, _ := rawMetadata["name"].(string); name != "" {
+					workloadNames = append(workloadNames, name)
+				}
+			}
+			documents = append(documents, fileDocuments...)
+		}
+
+		overrides, err := collectWorkloadOverrides(cmd, workloadNames)
+		if err != nil {
+			return err
+		}
+		if len(documents) != 1 && overrides[""].isSet() {
+			return fmt.Errorf("cannot use --%s, --%s, or --%s without a workload target when 0 or more than 1 score files are provided", generateCmdOverridePropertyFlag, generateCmdOverridesFileFlag, generateCmdImageFlag)
 		}
 
-		slices.Sort(args)
-		for _, arg := range args {
-			var rawWorkload map[string]interface{}
-			if raw, err := os.ReadFile(arg); err != nil {
-				return fmt.Errorf("failed to read input score file: %s: %w", arg, err)
-			} else if err = yaml.Unmarshal(raw, &rawWorkload); err != nil {
-				return fmt.Errorf("failed to decode input score file: %s: %w", arg, err)
+		seen := make(map[string]string, len(documents))
+		for _, document := range documents {
+			rawWorkload, arg := document.raw, document.name()
+
+			// Untargeted overrides only apply when there is a single score file, targeted overrides apply to the
+			// workload with the matching name.
+			rawMetadata, _ := rawWorkload["metadata"].(map[string]interface{})
+			workloadOverrides := overrides[""]
+			if target, _ := rawMetadata["name"].(string); target != "" {
+				workloadOverrides = workloadOverrides.merge(overrides[target])
+				delete(overrides, target)
 			}
 
 			// apply overrides
 
-			if v, _ := cmd.Flags().GetString(generateCmdOverridesFileFlag); v != "" {
-				if err := parseAndApplyOverrideFile(v, generateCmdOverridesFileFlag, rawWorkload); err != nil {
+			for _, overridesFile := range workloadOverrides.files {
+				if err := parseAndApplyOverrideFile(overridesFile, generateCmdOverridesFileFlag, rawWorkload); err != nil {
 					return err
 				}
 			}
 
 			// Now read, parse, and apply any override properties to the score files
-			if v, _ := cmd.Flags().GetStringArray(generateCmdOverridePropertyFlag); len(v) > 0 {
-				for _, overridePropertyEntry := range v {
-					if rawWorkload, err = parseAndApplyOverrideProperty(overridePropertyEntry, generateCmdOverridePropertyFlag, rawWorkload); err != nil {
-						return err
-					}
-				}
-			}
-
-			// Ensure transforms are applied (be a good citizen)
-			if changes, err := scoreschema.ApplyCommonUpgradeTransforms(rawWorkload); err != nil {
-				return fmt.Errorf("failed to upgrade spec: %w", err)
-			} else if len(changes) > 0 {
-				for _, change := range changes {
-					slog.Info(fmt.Sprintf("Applying backwards compatible upgrade %s", change))
+			for _, overridePropertyEntry := range workloadOverrides.properties {
+				if rawWorkload, err = parseAndApplyOverrideProperty(overridePropertyEntry, generateCmdOverridePropertyFlag, rawWorkload); err != nil {
+					return err
 				}
 			}
 
-			var workload scoretypes.Workload
-			if err = scoreschema.Validate(rawWorkload); err != nil {
+			// The converter moves the x-avassa extension blocks aside, as they are not part of the Score schema, and
+			// validates the rest
+			decoded, err := avassa.NewConverter().DecodeWorkload(rawWorkload)
+			if err != nil {
 				return fmt.Errorf("invalid score file: %s: %w", arg, err)
-			} else if err = scoreloader.MapSpec(&workload, rawWorkload); err != nil {
-				return fmt.Errorf("failed to decode input score file: %s: %w", arg, err)
-			}
-
-		// Apply image override
-			// First validate the --image flag value if provided
-			if v, _ := cmd.Flags().GetString(generateCmdImageFlag); strings.TrimSpace(v) == "." {
-				return fmt.Errorf("invalid --%s value: '.' is not a valid image name; please provide an explicit image name (e.g. 'repo/name:tag')", generateCmdImageFlag)
 			}
+			workload, extras := decoded.Spec, decoded.Extensions
 
+			// Apply image override. Container targeted images always replace the image, while the workload image
+			// is only used for containers with image == '.'.
 			for containerName, container := range workload.Containers {
-				if container.Image == "." {
-					if v, _ := cmd.Flags().GetString(generateCmdImageFlag); v != "" {
-						if strings.TrimSpace(v) == "." {
-							return fmt.Errorf("container '%s' has image '.'; please provide an explicit image name via --%s", containerName, generateCmdImageFlag)
-						}
+				if v, ok := workloadOverrides.images[containerName]; ok {
+					container.Image = v
+					slog.Info(fmt.Sprintf("Set container image for container '%s' to %s from --%s", containerName, v, generateCmdImageFlag))
+					workload.Containers[containerName] = container
+				} else if container.Image == "." {
+					if v := workloadOverrides.images[""]; v != "" {
 						container.Image = v
 						slog.Info(fmt.Sprintf("Set container image for container '%s' to %s from --%s", containerName, v, generateCmdImageFlag))
 						workload.Containers[containerName] = container
@@ -130,27 +164,40 @@ var generateCmd = &cobra.Command{
 					}
 				}
 			}
+			for containerName := range workloadOverrides.images {
+				if _, ok := workload.Containers[containerName]; !ok && containerName != "" {
+					return fmt.Errorf("--%s: workload '%s' has no container named '%s'", generateCmdImageFlag, workload.Metadata["name"], containerName)
+				}
+			}
 
-			if currentState, err = currentState.WithWorkload(&workload, &arg, state.WorkloadExtras{}); err != nil {
+			// Several documents may define the same workload, the project would silently keep the last one
+			name, _ := workload.Metadata["name"].(string)
+			if other, ok := seen[name]; ok {
+				return fmt.Errorf("workload '%s' is given by both %s and %s", name, other, arg)
+			}
+			seen[name] = arg
+
+			if currentState, err = currentState.WithWorkload(&workload, document.file, extras); err != nil {
 				return fmt.Errorf("failed to add score file to project: %s: %w", arg, err)
 			}
 			slog.Info("Added score file to project", "file", arg)
 		}
 
-		if len(currentState.Workloads) == 0 {
-			return fmt.Errorf("project is empty, please add a score file")
+		delete(overrides, "")
+		if len(overrides) > 0 {
+			targets := slices.Sorted(maps.Keys(overrides))
+			return fmt.Errorf("overrides target workloads that are not in the provided score files: %s", strings.Join(targets, ", "))
 		}
 
-		if currentState, err = currentState.WithPrimedResources(); err != nil {
-			return fmt.Errorf("failed to prime resources: %w", err)
+		if len(currentState.Workloads) == 0 {
+			return fmt.Errorf("project is empty, please add a score file")
 		}
 
-		slog.Info("Primed resources", "#workloads", len(currentState.Workloads), "#resources", len(currentState.Resources))
-
-		outputManifests := make([]map[string]interface{}, 0)
-
-		if currentState, err = provisioners.ProvisionResources(currentState); err != nil {
-			return fmt.Errorf("failed to provision resources: %w", err)
+		// The converter primes and provisions the resources itself, the state only records the workloads
+		strict, _ := cmd.Flags().GetBool(generateCmdStrictFlag)
+		outputManifests, err := convertWorkloads(cmd, currentState, sd.Config, slices.Sorted(maps.Keys(currentState.Workloads)), strict)
+		if err != nil {
+			return err
 		}
 
 		sd.State = *currentState
@@ -159,24 +206,14 @@ var generateCmd = &cobra.Command{
 		}
 		slog.Info("Persisted state file")
 
-		for workloadName := range currentState.Workloads {
-			if manifest, err := convert.Workload(currentState, workloadName); err != nil {
-				return fmt.Errorf("failed to convert workloads: %w", err)
-			} else {
-				outputManifests = append(outputManifests, manifest)
-			}
-			slog.Info(fmt.Sprintf("Wrote manifest to manifests buffer for workload '%s'", workloadName))
+		if dir != "" {
+			return writeOutputDir(dir, format, outputManifests)
 		}
 
 		out := new(bytes.Buffer)
-        for _, manifest := range outputManifests {
-            if _, err := io.WriteString(out, "---\n"); err != nil {
-                return fmt.Errorf("failed to write document separator: %w", err)
-            }
-            if err := encodeManifestWithNameFirst(out, manifest); err != nil {
-                return fmt.Errorf("failed to encode manifest: %w", err)
-            }
-        }
+		if err := encodeManifests(out, format, outputManifests); err != nil {
+			return err
+		}
         v, _ := cmd.Flags().GetString(generateCmdOutputFlag)
         toStdout, _ := cmd.Flags().GetBool(generateCmdStdoutFlag)
         if toStdout || v == "-" {
@@ -196,6 +233,132 @@ var generateCmd = &cobra.Command{
     },
 }
 
+// convertWorkloads converts the workloads of the project and returns the applications of the named workloads in order.
+// The other workloads are only given as references, so conversion warnings about the named workloads alone are printed
+// to stderr, or returned as an error in strict mode.
+func convertWorkloads(cmd *cobra.Command, currentState *state.State, config avassa.Config, workloadNames []string, strict bool) ([]avassa.Application, error) {
+	diags := new(avassa.Diagnostics)
+	converter := avassa.NewConverter(
+		avassa.WithConfig(config),
+		avassa.WithStrict(strict),
+		avassa.WithDiagnostics(diags),
+		avassa.WithFileReader(os.ReadFile),
+	)
+	workloads := make([]avassa.Workload, 0, len(currentState.Workloads))
+	for _, name := range slices.Sorted(maps.Keys(currentState.Workloads)) {
+		ws := currentState.Workloads[name]
+		w := avassa.Workload{Spec: ws.Spec, Extensions: ws.Extras, Reference: !slices.Contains(workloadNames, name)}
+		if ws.File != nil {
+			w.File = *ws.File
+		}
+		workloads = append(workloads, w)
+	}
+	apps, err := converter.ConvertWorkloads(cmd.Context(), workloads)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]avassa.Application, 0, len(workloadNames))
+	for _, name := range workloadNames {
+		if i := slices.IndexFunc(apps, func(app avassa.Application) bool { return app.Workload == name }); i >= 0 {
+			out = append(out, apps[i])
+			slog.Info(fmt.Sprintf("Wrote manifest to manifests buffer for workload '%s'", name))
+		}
+	}
+	for _, w := range diags.Warnings() {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", w)
+	}
+	return out, nil
+}
+
+// outputDirSuffixes are the file suffixes of an application in --output-dir: the manifest written by generate and
+// the companion files that belong to the same application.
+var outputDirSuffixes = []string{".app.yaml", ".deployment.yaml", ".vault.yaml", ".app.json", ".deployment.json", ".vault.json"}
+
+// outputDirRecordFile records the files written to --output-dir, so that the next run only removes files that
+// generate wrote itself.
+const outputDirRecordFile = ".score-implementation-avassa-output.yaml"
+
+type outputDirRecord struct {
+	Files []string `yaml:"files"`
+}
+
+// writeOutputDir writes each application manifest to <app>.app.yaml (or .app.json) in the directory. Files written by
+// an earlier run that are not written again are removed, together with the companion files of applications that are
+// no longer generated. Other files, including the companion files of generated applications, are left alone.
+func writeOutputDir(dir string, format string, apps []avassa.Application) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory '%s': %w", dir, err)
+	}
+	var previous outputDirRecord
+	if raw, err := os.ReadFile(filepath.Join(dir, outputDirRecordFile)); err == nil {
+		if err := yaml.Unmarshal(raw, &previous); err != nil {
+			return fmt.Errorf("failed to decode '%s': %w", filepath.Join(dir, outputDirRecordFile), err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read '%s': %w", filepath.Join(dir, outputDirRecordFile), err)
+	}
+
+	written := make(map[string]bool, len(apps))
+	generated := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		fileName := app.Name + ".app.yaml"
+		out := new(bytes.Buffer)
+		switch format {
+		case outputFormatYAML:
+			if err := encodeManifestYAML(out, app); err != nil {
+				return err
+			}
+		case outputFormatJSON, outputFormatNDJSON:
+			fileName = app.Name + ".app.json"
+			if err := encodeManifestJSON(out, app, format == outputFormatJSON); err != nil {
+				return err
+			}
+		default:
+			return validateOutputFormat(format)
+		}
+		path := filepath.Join(dir, fileName)
+		if err := os.WriteFile(path+".tmp", out.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		} else if err := os.Rename(path+".tmp", path); err != nil {
+			return fmt.Errorf("failed to complete writing output file: %w", err)
+		}
+		written[fileName] = true
+		generated[app.Name] = true
+		slog.Info(fmt.Sprintf("Wrote manifest to '%s'", path))
+	}
+
+	for _, fileName := range previous.Files {
+		// the record only names files within the directory
+		if written[fileName] || filepath.Base(fileName) != fileName {
+			continue
+		}
+		stale := []string{fileName}
+		appName := strings.TrimSuffix(strings.TrimSuffix(fileName, ".app.yaml"), ".app.json")
+		if !generated[appName] {
+			stale = stale[:0]
+			for _, suffix := range outputDirSuffixes {
+				stale = append(stale, appName+suffix)
+			}
+		}
+		for _, name := range stale {
+			if err := os.Remove(filepath.Join(dir, name)); err == nil {
+				slog.Info(fmt.Sprintf("Removed stale output file '%s'", name))
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove stale output file: %w", err)
+			}
+		}
+	}
+
+	raw, err := yaml.Marshal(outputDirRecord{Files: slices.Sorted(maps.Keys(written))})
+	if err != nil {
+		return fmt.Errorf("failed to encode '%s': %w", outputDirRecordFile, err)
+	} else if err := os.WriteFile(filepath.Join(dir, outputDirRecordFile), raw, 0644); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", filepath.Join(dir, outputDirRecordFile), err)
+	}
+	return nil
+}
+
 func parseAndApplyOverrideFile(entry string, flagName string, spec map[string]interface{}) error {
 	if raw, err := os.ReadFile(entry); err != nil {
 		return fmt.Errorf("--%s '%s' is invalid, failed to read file: %w", flagName, entry, err)
@@ -237,88 +400,110 @@ func parseAndApplyOverrideProperty(entry string, flagName string, spec map[strin
 	}
 }
 
-func init() {
-    generateCmd.Flags().StringP(generateCmdOutputFlag, "o", "manifests.yaml", "The output manifests file to write the manifests to")
-    generateCmd.Flags().Bool(generateCmdStdoutFlag, false, "Write the generated manifests to stdout instead of a file")
-    generateCmd.Flags().String(generateCmdOverridesFileFlag, "", "An optional file of Score overrides to merge in")
-    generateCmd.Flags().StringArray(generateCmdOverridePropertyFlag, []string{}, "An optional set of path=key overrides to set or remove")
-    generateCmd.Flags().String(generateCmdImageFlag, "", "An optional container image to use for any container with image == '.'")
-    rootCmd.AddCommand(generateCmd)
+// workloadOverrides is the set of override flag values that apply to a single workload.
+type workloadOverrides struct {
+	files      []string
+	properties []string
+	// images maps a container name to its image, the empty key is used for any container with image == '.'.
+	images map[string]string
 }
 
-// encodeManifestWithNameFirst encodes the manifest as YAML ensuring that within
-// any object under the "containers" list, the "name" key is emitted first.
-// Other keys are emitted in lexicographical order for determinism.
-func encodeManifestWithNameFirst(w io.Writer, manifest map[string]interface{}) error {
-    n := toYAMLNode(manifest, "")
-    enc := yaml.NewEncoder(w)
-    defer enc.Close()
-    return enc.Encode(n)
+func (o workloadOverrides) isSet() bool {
+	return len(o.files) > 0 || len(o.properties) > 0 || len(o.images) > 0
 }
 
-func toYAMLNode(v interface{}, parentKey string) *yaml.Node {
-    switch t := v.(type) {
-    case nil:
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
-    case string:
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}
-    case bool:
-        if t {
-            return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}
-        }
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
-    case int:
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", t)}
-    case int64:
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", t)}
-    case float64:
-        // Keep integers clean even if represented as float64
-        if float64(int64(t)) == t {
-            return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", int64(t))}
-        }
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strings.TrimRight(strings.TrimRight(fmt.Sprintf("%f", t), "0"), ".")}
-    case []interface{}:
-        seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
-        for _, el := range t {
-            seq.Content = append(seq.Content, toYAMLNode(el, parentKey))
-        }
-        return seq
-    case map[string]interface{}:
-        // Determine key order
-        keys := make([]string, 0, len(t))
-        for k := range t {
-            keys = append(keys, k)
-        }
-        // When within containers, place "name" first if present
-        if parentKey == "containers" {
-            sort.Strings(keys)
-            // Move "name" to the front if it exists
-            for i, k := range keys {
-                if k == "name" {
-                    if i != 0 {
-                        copy(keys[1:i+1], keys[0:i])
-                        keys[0] = k
-                    }
-                    break
-                }
-            }
-        } else {
-            sort.Strings(keys)
-        }
-        m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
-        for _, k := range keys {
-            m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k})
-            m.Content = append(m.Content, toYAMLNode(t[k], k))
-        }
-        return m
-    default:
-        // Fallback: encode via yaml then decode to node (rare)
-        var n yaml.Node
-        if raw, err := yaml.Marshal(t); err == nil {
-            _ = yaml.Unmarshal(raw, &n)
-            return &n
-        }
-        // As a last resort, string-format
-        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprintf("%v", t)}
-    }
+// merge returns the combination of both sets of overrides, the other overrides are applied after the receiver.
+func (o workloadOverrides) merge(other workloadOverrides) workloadOverrides {
+	out := workloadOverrides{
+		files:      slices.Concat(o.files, other.files),
+		properties: slices.Concat(o.properties, other.properties),
+		images:     maps.Clone(o.images),
+	}
+	if len(other.images) > 0 && out.images == nil {
+		out.images = make(map[string]string, len(other.images))
+	}
+	maps.Copy(out.images, other.images)
+	return out
+}
+
+// collectWorkloadOverrides groups the override flags by the workload they target. Untargeted overrides are stored
+// under the empty key. The targeted forms are:
+//
+//	--overrides-file <workload>=<file>
+//	--override-property <workload>:<path>=<value>
+//	--image <workload>=<image> or --image <workload>/<container>=<image>
+//
+// File paths may contain =, so an overrides file is only targeted when the part before the = is one of the given
+// workload names. An empty --image is ignored, as it was before images could be targeted.
+func collectWorkloadOverrides(cmd *cobra.Command, workloadNames []string) (map[string]workloadOverrides, error) {
+	out := make(map[string]workloadOverrides)
+
+	overrideFiles, _ := cmd.Flags().GetStringArray(generateCmdOverridesFileFlag)
+	for _, entry := range overrideFiles {
+		target, file := "", entry
+		if before, after, ok := strings.Cut(entry, "="); ok && slices.Contains(workloadNames, before) {
+			target, file = before, after
+			if file == "" {
+				return nil, fmt.Errorf("--%s '%s' is invalid, expected <workload>=<file> or <file>", generateCmdOverridesFileFlag, entry)
+			}
+		}
+		o := out[target]
+		o.files = append(o.files, file)
+		out[target] = o
+	}
+
+	overrideProperties, _ := cmd.Flags().GetStringArray(generateCmdOverridePropertyFlag)
+	for _, entry := range overrideProperties {
+		target, property := "", entry
+		path, _, _ := strings.Cut(entry, "=")
+		if before, after, ok := strings.Cut(path, ":"); ok && slices.Contains(workloadNames, before) {
+			target, property = before, strings.TrimPrefix(entry, before+":")
+			if after == "" {
+				return nil, fmt.Errorf("--%s '%s' is invalid, expected <workload>:<path>=<value> or <path>=<value>", generateCmdOverridePropertyFlag, entry)
+			}
+		}
+		o := out[target]
+		o.properties = append(o.properties, property)
+		out[target] = o
+	}
+
+	images, _ := cmd.Flags().GetStringArray(generateCmdImageFlag)
+	for _, entry := range images {
+		if entry == "" {
+			continue
+		}
+		target, container, image := "", "", entry
+		if before, after, ok := strings.Cut(entry, "="); ok {
+			target, image = before, after
+			target, container, _ = strings.Cut(target, "/")
+			if target == "" || (strings.Contains(before, "/") && container == "") {
+				return nil, fmt.Errorf("--%s '%s' is invalid, expected <workload>[/<container>]=<image> or <image>", generateCmdImageFlag, entry)
+			}
+		}
+		if strings.TrimSpace(image) == "." || strings.TrimSpace(image) == "" {
+			return nil, fmt.Errorf("invalid --%s value: '%s' is not a valid image name; please provide an explicit image name (e.g. 'repo/name:tag')", generateCmdImageFlag, strings.TrimSpace(image))
+		}
+		o := out[target]
+		if o.images == nil {
+			o.images = make(map[string]string)
+		}
+		if _, ok := o.images[container]; ok {
+			return nil, fmt.Errorf("--%s '%s' conflicts with an earlier --%s for the same target", generateCmdImageFlag, entry, generateCmdImageFlag)
+		}
+		o.images[container] = image
+		out[target] = o
+	}
+	return out, nil
+}
+
+func init() {
+    generateCmd.Flags().StringP(generateCmdOutputFlag, "o", "manifests.yaml", "The output manifests file to write the manifests to")
+    generateCmd.Flags().Bool(generateCmdStdoutFlag, false, "Write the generated manifests to stdout instead of a file")
+    generateCmd.Flags().String(generateCmdOutputDirFlag, "", "Write one <app>.app.yaml file per application into this directory instead of a single output file, removing the files it wrote for applications that are no longer generated")
+    generateCmd.Flags().String(generateCmdFormatFlag, outputFormatYAML, "The output format: yaml, json (an array of applications) or ndjson (one application per line)")
+    generateCmd.Flags().Bool(generateCmdStrictFlag, false, "Fail instead of warning when parts of a Score file cannot be converted")
+    generateCmd.Flags().StringArray(generateCmdOverridesFileFlag, []string{}, "An optional file of Score overrides to merge in, use <workload>=<file> to target a single workload")
+    generateCmd.Flags().StringArray(generateCmdOverridePropertyFlag, []string{}, "An optional set of path=key overrides to set or remove, use <workload>:<path>=<value> to target a single workload")
+    generateCmd.Flags().StringArray(generateCmdImageFlag, []string{}, "An optional container image to use for any container with image == '.', use <workload>=<image> or <workload>/<container>=<image> to target a workload or container")
+    rootCmd.AddCommand(generateCmd)
 }
@@ -0,0 +1,182 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScoreFile(t *testing.T, path string, name string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: `+name+`
+containers:
+  main:
+    image: busybox
+`), 0644))
+}
+
+func generatedNames(stdout string) []string {
+	var out []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if name, ok := strings.CutPrefix(line, "name: "); ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func TestGenerateFromStdin(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		rootCmd.SetIn(nil)
+	})
+
+	rootCmd.SetIn(strings.NewReader(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: first
+containers:
+  main:
+    image: busybox
+---
+apiVersion: score.dev/v1b1
+metadata:
+  name: second
+containers:
+  main:
+    image: .
+---
+`))
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--image", "second=nginx", "-"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, generatedNames(stdout))
+	assert.Contains(t, stdout, "image: nginx\n")
+
+	// untargeted overrides need a single workload, whatever the number of files
+	rootCmd.SetIn(strings.NewReader("apiVersion: score.dev/v1b1\nmetadata:\n  name: first\n---\napiVersion: score.dev/v1b1\nmetadata:\n  name: second\n"))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--image", "nginx", "-"})
+	assert.EqualError(t, err, "cannot use --override-property, --overrides-file, or --image without a workload target when 0 or more than 1 score files are provided")
+
+	rootCmd.SetIn(strings.NewReader(""))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "-"})
+	assert.EqualError(t, err, "failed to decode input score file: stdin: no documents")
+}
+
+func TestGenerateExpandsDirectoriesAndGlobs(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove("score.yaml"))
+	writeScoreFile(t, "services/api/score.yaml", "api")
+	writeScoreFile(t, "services/web/frontend/score-web.yaml", "web")
+	writeScoreFile(t, "services/web/other.yaml", "other")
+	writeScoreFile(t, "services/.old/score.yaml", "old")
+	require.NoError(t, os.MkdirAll("empty", 0755))
+
+	for _, args := range [][]string{
+		{"./services/..."},
+		{"services"},
+		{"services/*"},
+		{"services/*/score.yaml", "services/web/frontend/score-web.yaml", "services/api/score.yaml"},
+		{"./services/...", "./services/api/score.yaml"},
+		{"services/api/../api/score.yaml", "services/api/score.yaml", "services/web/frontend"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, append([]string{"generate", "--stdout"}, args...))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"api", "web"}, generatedNames(stdout))
+		})
+	}
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "services/*.yml"})
+	assert.EqualError(t, err, "no score files match 'services/*.yml'")
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "empty"})
+	assert.EqualError(t, err, "no score*.yaml files found in 'empty'")
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "missing/..."})
+	assert.EqualError(t, err, "'missing' is not a directory")
+}
+
+func TestGenerateMultiDocumentFile(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove("score.yaml"))
+	require.NoError(t, os.MkdirAll("services", 0755))
+	require.NoError(t, os.WriteFile("services/app.conf", []byte("key = value\n"), 0644))
+	require.NoError(t, os.WriteFile("services/score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: first
+containers:
+  main:
+    image: busybox
+---
+# an empty document is skipped
+---
+apiVersion: score.dev/v1b1
+metadata:
+  name: second
+containers:
+  main:
+    image: busybox
+    files:
+      /etc/app.conf:
+        source: app.conf
+`), 0644))
+
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "services/score.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, generatedNames(stdout))
+
+	require.NoError(t, os.WriteFile("broken.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: third
+containers:
+  main:
+    image: busybox
+---
+apiVersion: score.dev/v1b1
+metadata:
+  name: fourth
+`), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "broken.yaml"})
+	assert.ErrorContains(t, err, "invalid score file: broken.yaml: document 1: jsonschema")
+
+	require.NoError(t, os.WriteFile("broken.yaml", []byte("apiVersion: score.dev/v1b1\n---\n- a list\n"), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "broken.yaml"})
+	assert.ErrorContains(t, err, "failed to decode input score file: broken.yaml: document 1: yaml: unmarshal errors")
+
+	require.NoError(t, os.WriteFile("broken.yaml", []byte("apiVersion: score.dev/v1b1\n---\nmetadata: [\n"), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "broken.yaml"})
+	assert.ErrorContains(t, err, "failed to decode input score file: broken.yaml: document 1: yaml: ")
+
+	require.NoError(t, os.WriteFile("broken.yaml", []byte("apiVersion: score.dev/v1b1\nmetadata:\n  name: first\ncontainers:\n  main:\n    image: busybox\n"), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "broken.yaml", "services/score.yaml"})
+	assert.EqualError(t, err, "workload 'first' is given by both broken.yaml and services/score.yaml: document 0")
+}
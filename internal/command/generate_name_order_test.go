@@ -4,6 +4,7 @@ import (
     "bytes"
     "context"
     "os"
+    "strings"
     "testing"
 
     "github.com/stretchr/testify/assert"
@@ -74,3 +75,67 @@ func TestGenerate_ContainersNameFirst(t *testing.T) {
     }
 }
 
+
+func mappingKeys(n *yaml.Node) []string {
+    keys := make([]string, 0, len(n.Content)/2)
+    for i := 0; i < len(n.Content); i += 2 {
+        keys = append(keys, n.Content[i].Value)
+    }
+    return keys
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+    for i := 0; i < len(n.Content); i += 2 {
+        if n.Content[i].Value == key {
+            return n.Content[i+1]
+        }
+    }
+    return nil
+}
+
+func TestGenerate_SchemaKeyOrder(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.io/version: "1.0"
+    avassa.io/variable.REGION: north
+    avassa.upgrade-from.method: stop-and-restart
+containers:
+  main:
+    image: busybox
+    variables:
+      AREA: $${REGION}
+    livenessProbe:
+      httpGet:
+        scheme: HTTP
+        path: /live
+        port: 8080
+    readinessProbe:
+      exec:
+        command: [sh, -c, "true"]
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc yaml.Node
+    require.NoError(t, yaml.NewDecoder(strings.NewReader(stdout)).Decode(&doc))
+    root := doc.Content[0]
+    assert.Equal(t, []string{"name", "version", "services", "on-mutable-variable-change", "upgrade-from"}, mappingKeys(root))
+
+    svc := mappingValue(root, "services").Content[0]
+    assert.Equal(t, []string{"name", "mode", "replicas", "share-pid-namespace", "variables", "containers"}, mappingKeys(svc))
+    assert.Equal(t, []string{"name", "value"}, mappingKeys(mappingValue(svc, "variables").Content[0]))
+
+    container := mappingValue(svc, "containers").Content[0]
+    assert.Equal(t, []string{"name", "image", "container-log-size", "shutdown-timeout", "mounts", "env", "probes"}, mappingKeys(container))
+    probes := mappingValue(container, "probes")
+    assert.Equal(t, []string{"readiness", "liveness"}, mappingKeys(probes))
+    assert.Equal(t, []string{"scheme", "port", "path"}, mappingKeys(mappingValue(mappingValue(probes, "liveness"), "http")))
+
+    assert.Equal(t, []string{"method", "version-regexp"}, mappingKeys(mappingValue(root, "upgrade-from").Content[0]))
+}
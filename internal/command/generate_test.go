@@ -17,8 +17,10 @@ package command
 import (
     "bytes"
     "context"
+    "encoding/json"
     "os"
     "path/filepath"
+    "strings"
     "testing"
 
     "github.com/stretchr/testify/assert"
@@ -252,13 +254,907 @@ func TestGenerateStdoutFlag(t *testing.T) {
         "generate", "--stdout", "--", "score.yaml",
     })
     require.NoError(t, err)
-    // Should emit YAML to stdout and no logs to stderr
+    // Should emit YAML to stdout and only conversion warnings to stderr
     assert.NotEqual(t, "", stdout)
     assert.Contains(t, stdout, "---\n")
-    assert.Equal(t, "", stderr)
+    assert.Equal(t, "warning: workload: example: service.ports: service ports are not supported and were dropped, expose them through x-avassa network settings instead"+"\n", stderr)
 
     // And should not create manifests.yaml by default
     if _, err := os.Stat("manifests.yaml"); err == nil {
         t.Fatalf("manifests.yaml should not have been created when using --stdout")
     }
 }
+
+func TestGenerateTargetedOverridesWithMultipleFiles(t *testing.T) {
+    _ = changeToTempDir(t)
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    assert.Equal(t, "", stdout)
+
+    require.NoError(t, os.WriteFile("other.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: other
+containers:
+  main:
+    image: .
+  sidecar:
+    image: busybox
+`), 0644))
+    require.NoError(t, os.WriteFile("overrides.yaml", []byte(`
+metadata:
+  annotations:
+    avassa.replicas: "3"
+`), 0644))
+
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout",
+        "--override-property", "example:containers.main.image=stefanprodan/podinfo:6.0.0",
+        "--overrides-file", "other=overrides.yaml",
+        "--image", "other=repo/img:tag",
+        "--image", "other/sidecar=busybox:1.36",
+        "--", "score.yaml", "other.yaml",
+    })
+    require.NoError(t, err)
+    assert.Contains(t, stdout, "image: stefanprodan/podinfo:6.0.0\n")
+    assert.Contains(t, stdout, "image: repo/img:tag\n")
+    assert.Contains(t, stdout, "image: busybox:1.36\n")
+    assert.Contains(t, stdout, "replicas: 3\n")
+}
+
+func TestGenerateRejectsUntargetedOverridesWithMultipleFiles(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("other.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: other
+containers:
+  main:
+    image: busybox
+`), 0644))
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "containers.main.image=busybox:1.36", "--", "score.yaml", "other.yaml",
+    })
+    assert.EqualError(t, err, "cannot use --override-property, --overrides-file, or --image without a workload target when 0 or more than 1 score files are provided")
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--image", "missing=busybox:1.36", "--", "score.yaml", "other.yaml",
+    })
+    assert.EqualError(t, err, "overrides target workloads that are not in the provided score files: missing")
+
+    // the part before the : is only a target when it names one of the workloads
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "missing:containers.main.image=busybox:1.36", "--", "score.yaml", "other.yaml",
+    })
+    assert.EqualError(t, err, "cannot use --override-property, --overrides-file, or --image without a workload target when 0 or more than 1 score files are provided")
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--image", "other/missing=busybox", "--", "score.yaml", "other.yaml",
+    })
+    assert.EqualError(t, err, "--image: workload 'other' has no container named 'missing'")
+}
+
+func TestGenerateOverridesFileWithEqualsInPath(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("env=prod.yaml", []byte(`
+metadata:
+  annotations:
+    avassa.replicas: "2"
+`), 0644))
+
+    // the part before the = is not a workload name, so this is a file path
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--overrides-file", "env=prod.yaml", "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    assert.Contains(t, stdout, "replicas: 2\n")
+
+    // an empty image is the same as no image
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--image", "", "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    assert.Contains(t, stdout, "replicas: 1\n")
+
+    // likewise the part before a : is not a workload name, so the property path holds the :
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "containers.main.variables.note:v1=a:b", "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    sd, ok, err := state.LoadStateDirectory(".")
+    require.NoError(t, err)
+    require.True(t, ok)
+    assert.Equal(t, "a:b", sd.State.Workloads["example"].Spec.Containers["main"].Variables["note:v1"])
+}
+
+func TestGenerateMergesAvassaExtensions(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.on-mounted-file-change-restart: "true"
+x-avassa:
+  version: "2.0"
+  network:
+    shared-application-network: shared
+  service:
+    placement:
+      match-host-labels: camera
+containers:
+  main:
+    image: busybox
+    x-avassa:
+      container-log-size: 200 MB
+      on-mounted-file-change:
+        restart: false
+      security:
+        apparmor:
+          disabled: true
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    assert.Equal(t, "2.0", doc["version"])
+    assert.Equal(t, map[string]interface{}{"shared-application-network": "shared"}, doc["network"])
+    svc := doc["services"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{"match-host-labels": "camera"}, svc["placement"])
+    c0 := svc["containers"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, "200 MB", c0["container-log-size"])
+    assert.Equal(t, map[string]interface{}{"restart": false}, c0["on-mounted-file-change"])
+    assert.Equal(t, map[string]interface{}{"apparmor": map[string]interface{}{"disabled": true}}, c0["security"])
+
+    // the extension blocks are kept with the workload in the state rather than in the score spec
+    sd, ok, err := state.LoadStateDirectory(".")
+    require.NoError(t, err)
+    require.True(t, ok)
+    assert.Equal(t, "200 MB", sd.State.Workloads["example"].Extras.ContainerAvassa["main"]["container-log-size"])
+}
+
+func TestGenerateRejectsInvalidAvassaExtension(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+containers:
+  main:
+    image: busybox
+    x-avassa: [nope]
+`), 0644))
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    assert.EqualError(t, err, "invalid score file: score.yaml: containers: main: x-avassa: expected a map")
+}
+
+func TestGeneratePlacementResolvesWorkloadNames(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("driver.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: camera-driver
+  annotations:
+    avassa.placement.match-host-labels: camera
+containers:
+  main:
+    image: driver
+`), 0644))
+    require.NoError(t, os.WriteFile("processing.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: camera-processing
+  annotations:
+    avassa.placement.preferred-affinity: camera-driver, other-app.other-service
+x-avassa:
+  service:
+    placement:
+      preferred-anti-affinity:
+        services: [camera-processing]
+containers:
+  main:
+    image: processing
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "driver.yaml", "processing.yaml"})
+    require.NoError(t, err)
+
+    placements := map[string]interface{}{}
+    dec := yaml.NewDecoder(bytes.NewReader([]byte(stdout)))
+    for {
+        var doc map[string]interface{}
+        if dec.Decode(&doc) != nil {
+            break
+        }
+        svc := doc["services"].([]interface{})[0].(map[string]interface{})
+        placements[doc["name"].(string)] = svc["placement"]
+    }
+    assert.Equal(t, map[string]interface{}{
+        "camera-driver": map[string]interface{}{"match-host-labels": "camera"},
+        "camera-processing": map[string]interface{}{
+            "preferred-affinity":      map[string]interface{}{"services": []interface{}{"camera-driver.camera-driver-service", "other-app.other-service"}},
+            "preferred-anti-affinity": map[string]interface{}{"services": []interface{}{"camera-processing-service"}},
+        },
+    }, placements)
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "camera-driver:metadata.annotations.avassa\\.placement\\.preferred-affinity=unknown", "--", "driver.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: camera-driver: placement: preferred-affinity: unknown workload 'unknown', use APPNAME.SERVICENAME to refer to services outside of the project")
+}
+
+func TestGenerateOnePerMatchingHostMode(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: log-shipper
+  annotations:
+    avassa.io/mode: one-per-matching-host
+    avassa.placement.match-host-labels: logging
+containers:
+  main:
+    image: fluent-bit
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    svc := doc["services"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, "one-per-matching-host", svc["mode"])
+    assert.NotContains(t, svc, "replicas")
+    assert.Equal(t, map[string]interface{}{"match-host-labels": "logging"}, svc["placement"])
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "metadata.annotations.avassa\\.replicas=\"2\"", "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: log-shipper: mode: avassa.replicas cannot be set when the mode is 'one-per-matching-host'")
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "metadata.annotations.avassa\\.io/mode=global", "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: log-shipper: mode: unsupported mode 'global', expected 'replicated' or 'one-per-matching-host'")
+}
+
+func TestGenerateProbesFromAnnotations(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.probes.readiness.tcp-port: "8080"
+    avassa.probes.startup.from: liveness
+    avassa.probes.startup.failure-threshold: "30"
+    avassa.containers.main.probes.liveness.period: 5s
+    avassa.containers.main.probes.liveness.timeout: 2s
+containers:
+  main:
+    image: busybox
+    livenessProbe:
+      httpGet:
+        path: /live
+        port: 8080
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    c0 := doc["services"].([]interface{})[0].(map[string]interface{})["containers"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{
+        "liveness": map[string]interface{}{
+            "http":    map[string]interface{}{"path": "/live", "port": 8080},
+            "period":  "5s",
+            "timeout": "2s",
+        },
+        "readiness": map[string]interface{}{
+            "tcp": map[string]interface{}{"port": 8080},
+        },
+        "startup": map[string]interface{}{
+            "http":              map[string]interface{}{"path": "/live", "port": 8080},
+            "failure-threshold": 30,
+        },
+    }, c0["probes"])
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", "metadata.annotations.avassa\\.probes\\.liveness\\.initial-delay=5 seconds", "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: example: container: main: probes: liveness: initial-delay: '5 seconds' is not a valid duration, expected a value like 30s, 5m or 1h30m")
+}
+
+func TestGenerateSecuritySettingsAndPolicy(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: modbus
+  annotations:
+    avassa.additional-capabilities: CAP_CHOWN
+    avassa.containers.io.additional-capabilities: NET_ADMIN, chown
+    avassa.containers.io.user: "1000:1000"
+    avassa.user-namespace.host: "true"
+    avassa.security.selinux.disabled: "true"
+containers:
+  io:
+    image: modbus-io
+  web:
+    image: modbus-web
+`), 0644))
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    assert.EqualError(t, err, "failed to convert workloads: workload: modbus: container: io: additional-capabilities: 'net-admin' is a privileged capability and must be listed in security.allowed-capabilities of the project config")
+
+    require.NoError(t, os.WriteFile(filepath.Join(state.DefaultRelativeStateDirectory, state.ConfigFileName), []byte(`
+security:
+  allowed-capabilities: [NET_ADMIN]
+`), 0644))
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    containers := doc["services"].([]interface{})[0].(map[string]interface{})["containers"].([]interface{})
+    io, web := containers[0].(map[string]interface{}), containers[1].(map[string]interface{})
+    assert.Equal(t, []interface{}{"net-admin", "chown"}, io["additional-capabilities"])
+    assert.Equal(t, "1000:1000", io["user"])
+    assert.Equal(t, map[string]interface{}{"host": true}, io["user-namespace"])
+    assert.Equal(t, map[string]interface{}{"selinux": map[string]interface{}{"disabled": true}}, io["security"])
+    assert.Equal(t, []interface{}{"chown"}, web["additional-capabilities"])
+    assert.NotContains(t, web, "user")
+}
+
+func TestGenerateDevicesAndGPU(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: inference
+  annotations:
+    avassa.containers.main.devices.device-labels: example.com/camera, serial
+    avassa.containers.main.gpu.labels: gpu
+    avassa.containers.main.gpu.number-gpus: "1"
+    avassa.containers.main.gpu.gpu-patterns: vendor=="NVIDIA*",memory>="8 GiB"; name=="Tesla*"
+containers:
+  main:
+    image: inference
+  sidecar:
+    image: exporter
+    x-avassa:
+      devices:
+        device-labels: [example.com/camera]
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    containers := doc["services"].([]interface{})[0].(map[string]interface{})["containers"].([]interface{})
+    main, sidecar := containers[0].(map[string]interface{}), containers[1].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{"device-labels": []interface{}{"example.com/camera", "serial"}}, main["devices"])
+    assert.Equal(t, map[string]interface{}{
+        "labels":       []interface{}{"gpu"},
+        "number-gpus":  1,
+        "gpu-patterns": []interface{}{`vendor=="NVIDIA*",memory>="8 GiB"`, `name=="Tesla*"`},
+    }, main["gpu"])
+    assert.Equal(t, map[string]interface{}{"device-labels": []interface{}{"example.com/camera"}}, sidecar["devices"])
+    assert.NotContains(t, sidecar, "gpu")
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.containers\.main\.gpu\.gpu-patterns=vendor>"NVIDIA"`, "--", "score.yaml",
+    })
+    assert.EqualError(t, err, `failed to convert workloads: workload: inference: container: main: gpu: gpu-patterns: 'vendor>"NVIDIA"' is not a valid gpu pattern, '>' can only be used with memory, driver-version, compute-capability`)
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.containers\.main\.devices\.device-labels=bad label`, "--", "score.yaml",
+    })
+    assert.EqualError(t, err, `failed to convert workloads: workload: inference: container: main: devices: device-labels: 'bad label' is not a valid label name, the name must be alphanumeric with '-', '_' or '.' inside`)
+}
+
+func TestGenerateOutboundAccessFromResources(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.outbound-access.rules: 10.0.0.0/8, 10.1.2.3=deny
+containers:
+  main:
+    image: busybox
+resources:
+  db:
+    type: service
+    params:
+      address: 192.168.1.10:5432
+  api:
+    type: dns
+    params:
+      host: API.example.com
+`), 0644))
+
+    // explicit rules need a default action, a deny rule would otherwise block all other egress
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    assert.EqualError(t, err, "failed to convert workloads: workload: example: outbound-access: rules: a default action is required, set the avassa.outbound-access.default-action annotation or network.outbound-default-action in the project config")
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.outbound-access\.default-action=deny`, "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    svc := doc["services"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{
+        "outbound-access": map[string]interface{}{
+            "default-action": "deny",
+            "rules": map[string]interface{}{
+                "192.168.1.10/32": "allow",
+                "api.example.com": "allow",
+                "10.0.0.0/8":      "allow",
+                "10.1.2.3/32":     "deny",
+            },
+        },
+    }, svc["network"])
+
+    // without explicit rules, outbound access is only generated once the project policy opts in
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.outbound-access\.rules=`, "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    assert.NotContains(t, stdout, "outbound-access")
+
+    require.NoError(t, os.WriteFile(filepath.Join(state.DefaultRelativeStateDirectory, state.ConfigFileName), []byte(`
+network:
+  outbound-default-action: deny
+`), 0644))
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.outbound-access\.rules=`, "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    svc = doc["services"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{
+        "outbound-access": map[string]interface{}{
+            "default-action": "deny",
+            "rules": map[string]interface{}{
+                "192.168.1.10/32": "allow",
+                "api.example.com": "allow",
+            },
+        },
+    }, svc["network"])
+
+    // the project default action also applies to explicit rules
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    assert.Contains(t, stdout, "10.1.2.3/32: deny\n")
+}
+
+func TestGenerateBandwidthLimits(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile(filepath.Join(state.DefaultRelativeStateDirectory, state.ConfigFileName), []byte(`
+network:
+  upstream-bandwidth-per-host: 1 Mbit/s
+  downstream-bandwidth-per-host: 5 Mbit/s
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.resources\.network\.upstream-bandwidth-per-host=512 Kibit/s`, "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    assert.Equal(t, map[string]interface{}{
+        "network": map[string]interface{}{
+            "upstream-bandwidth-per-host":   "512 Kibit/s",
+            "downstream-bandwidth-per-host": "5 Mbit/s",
+        },
+    }, doc["resources"])
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.resources\.network\.upstream-bandwidth-per-host=1 MB/s`, "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: example: resources: network: upstream-bandwidth-per-host: '1 MB/s' is not a valid bandwidth, expected a value like 10 Mbit/s, 512 Kibit/s or unlimited")
+}
+
+func TestGenerateUpgradeFromAndDelayedShutdown(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout",
+        "--override-property", `metadata.annotations.avassa\.upgrade-from\.method=per-service`,
+        "--override-property", `metadata.annotations.avassa\.upgrade-from\.version-regexp=1\..*`,
+        "--override-property", `metadata.annotations.avassa\.upgrade-from\.instances-in-parallel="2"`,
+        "--override-property", `metadata.annotations.avassa\.upgrade-from\.healthy-time=30s`,
+        "--override-property", `metadata.annotations.avassa\.delayed-shutdown\.timeout=5m`,
+        "--override-property", `metadata.annotations.avassa\.delayed-shutdown\.max-number-of-instances="3"`,
+        "--override-property", `metadata.annotations.avassa\.containers\.main\.delayed-shutdown-cmd='["sh", "-c", "drain --wait"]'`,
+        "--", "score.yaml",
+    })
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    assert.Equal(t, []interface{}{
+        map[string]interface{}{
+            "method":         "per-service",
+            "version-regexp": `1\..*`,
+            "services": []interface{}{
+                map[string]interface{}{"name": "example-service", "healthy-time": "30s", "instances-in-parallel": 2},
+            },
+        },
+    }, doc["upgrade-from"])
+    svc := doc["services"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{"timeout": "5m", "max-number-of-instances": 3}, svc["delayed-shutdown"])
+    container := svc["containers"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, []interface{}{"sh", "-c", "drain --wait"}, container["delayed-shutdown-cmd"])
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout",
+        "--override-property", `metadata.annotations.avassa\.upgrade-from\.method=stop-and-restart`,
+        "--override-property", `metadata.annotations.avassa\.upgrade-from\.instances-in-parallel="2"`,
+        "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: example: upgrade-from: 0: services can only be set when the method is 'per-service'")
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout",
+        "--override-property", `metadata.annotations.avassa\.delayed-shutdown-cmd=drain`,
+        "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: example: container: main: delayed-shutdown-cmd requires the service delayed-shutdown, set avassa.delayed-shutdown.timeout")
+}
+
+func TestGenerateHoistsServiceVariables(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.io/variable.REGION: eu-north
+containers:
+  main:
+    image: busybox
+    variables:
+      NAME: ${metadata.name}
+      AREA: $${REGION}
+`), 0644))
+
+    // hoisting resource outputs is covered with a provisioner in pkg/avassa, the CLI has no provisioner with outputs
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    svc := doc["services"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, []interface{}{
+        map[string]interface{}{"name": "REGION", "value": "eu-north"},
+    }, svc["variables"])
+    container := svc["containers"].([]interface{})[0].(map[string]interface{})
+    assert.Equal(t, map[string]interface{}{
+        "NAME": "example",
+        "AREA": "${REGION}",
+    }, container["env"])
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--stdout", "--override-property", `metadata.annotations.avassa\.io/variable\.bad-name=x`, "--", "score.yaml",
+    })
+    assert.EqualError(t, err, "failed to convert workloads: workload: example: variables: 'bad-name' is not a valid variable name, it must match ^[a-zA-Z_][a-zA-Z0-9_]*$")
+}
+
+func TestGenerateWarningsAndStrictMode(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.replica: "2"
+    avassa.log-archive: "yes"
+    avassa.containers.main.user: "1000"
+    avassa.containers.other.user: "1000"
+containers:
+  main:
+    image: busybox
+    resources:
+      requests:
+        cpu: 100m
+    files:
+      - target: /etc/app.conf
+        content: hello
+`), 0644))
+
+    stdout, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    assert.NotEqual(t, "", stdout)
+    assert.Equal(t, `warning: workload: example: containers.main.resources.requests: resource requests are not supported and were dropped
+warning: workload: example: metadata.annotations.avassa.containers.other.user: unknown container 'other', the annotation was ignored
+warning: workload: example: metadata.annotations.avassa.log-archive: 'yes' is not true or false, the default was used
+warning: workload: example: metadata.annotations.avassa.replica: unknown annotation, the annotation was ignored
+`, stderr)
+
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--strict", "--", "score.yaml"})
+    assert.EqualError(t, err, "conversion produced 4 warnings in strict mode: "+
+        "workload: example: containers.main.resources.requests: resource requests are not supported and were dropped; "+
+        "workload: example: metadata.annotations.avassa.containers.other.user: unknown container 'other', the annotation was ignored; "+
+        "workload: example: metadata.annotations.avassa.log-archive: 'yes' is not true or false, the default was used; "+
+        "workload: example: metadata.annotations.avassa.replica: unknown annotation, the annotation was ignored")
+    assert.Equal(t, "", stdout)
+}
+
+func TestGenerateDetectsApplicationNameCollisions(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("other.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: other
+containers:
+  main:
+    image: busybox
+x-avassa:
+  name: example
+`), 0644))
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml", "other.yaml"})
+    assert.EqualError(t, err, "application name collision: workloads 'example', 'other' all produce the application name 'example', rename all but one of them")
+}
+
+func TestGenerateTruncatesLongServiceNames(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    name := strings.Repeat("a", 55) + "-longnam"
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: `+name+`
+containers:
+  main:
+    image: busybox
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    assert.Equal(t, name, doc["name"])
+    svc := doc["services"].([]interface{})[0].(map[string]interface{})
+    // the service name would be 71 characters, so it is cut to 63 with a hash of the full name
+    assert.Equal(t, strings.Repeat("a", 54)+"-f4b63006", svc["name"])
+}
+
+func TestGenerateLabels(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile(filepath.Join(state.DefaultRelativeStateDirectory, state.ConfigFileName), []byte(`
+labels:
+  prefix: example.com
+`), 0644))
+    require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  labels:
+    tier: prod
+    zones: [north, south]
+    replicas: 3
+    other.org/team: edge
+containers:
+  main:
+    image: busybox
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--", "score.yaml"})
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal([]byte(stdout), &doc))
+    assert.Equal(t, map[string]interface{}{
+        "example.com/tier":     "prod",
+        "example.com/zones":    []interface{}{"north", "south"},
+        "example.com/replicas": "3",
+        "other.org/team":       "edge",
+    }, doc["labels"])
+
+    for _, tc := range []struct {
+        property string
+        err      string
+    }{
+        {`metadata.labels.tier=in production`, "labels: example.com/tier: 'in production' is not a valid label value, it must not contain whitespace or parentheses"},
+        {`metadata.labels.system/controller=x`, "labels: system/controller: the 'system/' prefix is reserved for labels assigned by Avassa"},
+        {`metadata.labels.bad_/name=x`, "labels: 'bad_/name' is not a valid label name, the prefix 'bad_' must be a DNS domain name"},
+        {`metadata.labels.example\.com/tier=x`, "labels: 'example.com/tier' and 'tier' both become the label 'example.com/tier'"},
+    } {
+        t.Run(tc.property, func(t *testing.T) {
+            _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--override-property", tc.property, "--", "score.yaml"})
+            assert.EqualError(t, err, "failed to convert workloads: workload: example: "+tc.err)
+        })
+    }
+}
+
+func outputDirNames(t *testing.T, dir string) []string {
+    t.Helper()
+    entries, err := os.ReadDir(dir)
+    require.NoError(t, err)
+    names := make([]string, 0, len(entries))
+    for _, e := range entries {
+        names = append(names, e.Name())
+    }
+    return names
+}
+
+func TestGenerateOutputDir(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("other.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: other
+containers:
+  main:
+    image: busybox
+`), 0644))
+    require.NoError(t, os.WriteFile("rename.yaml", []byte("x-avassa:\n  name: renamed\n"), 0644))
+    require.NoError(t, os.MkdirAll("out", 0755))
+    // files that generate did not write: a hand-written application and the companion files of a generated one
+    for _, name := range []string{"README.md", "visitor-counter.app.yaml", "example.deployment.yaml", "example.vault.yaml"} {
+        require.NoError(t, os.WriteFile(filepath.Join("out", name), []byte("name: "+name+"\n"), 0644))
+    }
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{
+        "generate", "--output-dir", "out", "--overrides-file", "other=rename.yaml", "--", "score.yaml", "other.yaml",
+    })
+    require.NoError(t, err)
+    assert.Equal(t, "", stdout)
+    require.NoError(t, os.WriteFile(filepath.Join("out", "renamed.deployment.yaml"), []byte("name: renamed-deployment\n"), 0644))
+    assert.Equal(t, []string{
+        ".score-implementation-avassa-output.yaml", "README.md", "example.app.yaml", "example.deployment.yaml",
+        "example.vault.yaml", "renamed.app.yaml", "renamed.deployment.yaml", "visitor-counter.app.yaml",
+    }, outputDirNames(t, "out"))
+
+    // renamed is no longer generated, so its manifest and companion files go, everything else stays
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--output-dir", "out", "--", "other.yaml"})
+    require.NoError(t, err)
+    assert.Equal(t, []string{
+        ".score-implementation-avassa-output.yaml", "README.md", "example.app.yaml", "example.deployment.yaml",
+        "example.vault.yaml", "other.app.yaml", "visitor-counter.app.yaml",
+    }, outputDirNames(t, "out"))
+    raw, err := os.ReadFile(filepath.Join("out", "other.app.yaml"))
+    require.NoError(t, err)
+    var doc map[string]interface{}
+    require.NoError(t, yaml.Unmarshal(raw, &doc))
+    assert.Equal(t, "other", doc["name"])
+    _, err = os.Stat("manifests.yaml")
+    assert.True(t, os.IsNotExist(err))
+
+    // switching the format replaces the manifests written before, companion files of generated applications stay
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--output-dir", "out", "--format", "json"})
+    require.NoError(t, err)
+    assert.Equal(t, []string{
+        ".score-implementation-avassa-output.yaml", "README.md", "example.app.json", "example.deployment.yaml",
+        "example.vault.yaml", "other.app.json", "visitor-counter.app.yaml",
+    }, outputDirNames(t, "out"))
+
+    // invalid flag combinations are rejected before the score files are added to the project
+    writeScoreFile(t, "extra.yaml", "extra")
+    before, err := os.ReadFile(filepath.Join(state.DefaultRelativeStateDirectory, state.FileName))
+    require.NoError(t, err)
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--output-dir", "out", "--stdout", "extra.yaml"})
+    assert.EqualError(t, err, "--output-dir cannot be combined with --stdout")
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--output-dir", "out", "-o", "manifests.yaml", "extra.yaml"})
+    assert.EqualError(t, err, "--output-dir cannot be combined with --output")
+    after, err := os.ReadFile(filepath.Join(state.DefaultRelativeStateDirectory, state.FileName))
+    require.NoError(t, err)
+    assert.Equal(t, string(before), string(after))
+}
+
+func TestGenerateJSONFormats(t *testing.T) {
+    _ = changeToTempDir(t)
+    _, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+    require.NoError(t, err)
+    require.NoError(t, os.WriteFile("other.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: other
+  annotations:
+    avassa.replicas: "3"
+containers:
+  main:
+    image: busybox
+`), 0644))
+
+    stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--format", "json", "--", "score.yaml", "other.yaml"})
+    require.NoError(t, err)
+    var apps []map[string]interface{}
+    require.NoError(t, json.Unmarshal([]byte(stdout), &apps))
+    require.Len(t, apps, 2)
+    assert.Contains(t, stdout, "\n  {\n    \"name\": ")
+    // integers stay integers rather than becoming floats or strings
+    assert.Contains(t, stdout, `"replicas": 3`)
+
+    stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--format", "ndjson"})
+    require.NoError(t, err)
+    lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+    require.Len(t, lines, 2)
+    for _, line := range lines {
+        var app map[string]interface{}
+        require.NoError(t, json.Unmarshal([]byte(line), &app))
+        assert.True(t, strings.HasPrefix(line, `{"name":`))
+    }
+    assert.Contains(t, stdout, `"containers":[{"name":"main",`)
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--output-dir", "out", "--format", "json"})
+    require.NoError(t, err)
+    raw, err := os.ReadFile(filepath.Join("out", "other.app.json"))
+    require.NoError(t, err)
+    var app map[string]interface{}
+    require.NoError(t, json.Unmarshal(raw, &app))
+    assert.Equal(t, "other", app["name"])
+
+    _, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--format", "toml"})
+    assert.EqualError(t, err, "--format: unsupported format 'toml', expected yaml, json or ndjson")
+}
+
+func TestGenerateDropsResourcesOfOlderStateFiles(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	// older versions recorded the provisioned resources in the state file
+	require.NoError(t, os.WriteFile(filepath.Join(state.DefaultRelativeStateDirectory, state.FileName), []byte(`
+workloads: {}
+resources:
+  postgres.default#example.db:
+    type: postgres
+    class: default
+    id: example.db
+    state: {}
+    source_workload: example
+    outputs:
+      password: secret
+    secret_outputs: [password]
+`), 0644))
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "score.yaml"})
+	require.NoError(t, err)
+	sd, ok, err := state.LoadStateDirectory(".")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Len(t, sd.State.Workloads, 1)
+	raw, err := os.ReadFile(filepath.Join(state.DefaultRelativeStateDirectory, state.FileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "resources: {}\n")
+	assert.NotContains(t, string(raw), "secret_outputs")
+}
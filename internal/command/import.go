@@ -0,0 +1,133 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/score-spec/score-implementation-avassa/pkg/avassa"
+)
+
+const (
+	importCmdFromFlag      = "from"
+	importCmdOutputDirFlag = "output-dir"
+	importCmdStdoutFlag    = "stdout"
+	importCmdOverwriteFlag = "overwrite"
+
+	importFromAvassa  = "avassa"
+	importFromCompose = "compose"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Convert existing Avassa application specs or compose files into Score workloads",
+	Long: `Convert Avassa applications into Score files, one workload per service, written as score-<workload>.yaml.
+
+Container env becomes Score variables, http and exec probes become Score probes and config map mounts become files.
+Probe timing, tcp probes and application settings that generate reads from annotations become annotations. Everything
+else is kept in x-avassa blocks so that generate produces the same application again.
+
+With --from compose the input files are docker compose files instead. Each compose service becomes a workload with a
+single container: ports become the protocols of an x-avassa ingress-ip-per-instance, environment the variables,
+named volumes volume resources, the healthcheck the liveness probe and depends_on resources of type service.`,
+	Args: cobra.MinimumNArgs(1),
+	CompletionOptions: cobra.CompletionOptions{
+		HiddenDefaultCmd: true,
+	},
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		from, _ := cmd.Flags().GetString(importCmdFromFlag)
+		importFunc := avassa.ImportApplications
+		switch from {
+		case importFromAvassa:
+		case importFromCompose:
+			importFunc = avassa.ImportCompose
+		default:
+			return fmt.Errorf("--%s: unsupported source '%s', expected %s or %s", importCmdFromFlag, from, importFromAvassa, importFromCompose)
+		}
+		outputDir, _ := cmd.Flags().GetString(importCmdOutputDirFlag)
+		toStdout, _ := cmd.Flags().GetBool(importCmdStdoutFlag)
+		if toStdout && outputDir != "" {
+			return fmt.Errorf("--%s and --%s cannot be used together", importCmdStdoutFlag, importCmdOutputDirFlag)
+		}
+		overwrite, _ := cmd.Flags().GetBool(importCmdOverwriteFlag)
+
+		diags := &avassa.Diagnostics{}
+		var workloads []avassa.ScoreWorkload
+		seen := map[string]string{}
+		for _, arg := range args {
+			raw, err := os.ReadFile(arg)
+			if err != nil {
+				return fmt.Errorf("failed to read input file: %s: %w", arg, err)
+			}
+			imported, err := importFunc(raw, diags)
+			if err != nil {
+				return fmt.Errorf("failed to import: %s: %w", arg, err)
+			}
+			for _, w := range imported {
+				if other, ok := seen[w.Name()]; ok {
+					return fmt.Errorf("workload '%s' is imported from both %s and %s", w.Name(), other, arg)
+				}
+				seen[w.Name()] = arg
+			}
+			workloads = append(workloads, imported...)
+		}
+		for _, d := range diags.Warnings() {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", d)
+		}
+
+		for i, w := range workloads {
+			raw, err := yaml.Marshal(w)
+			if err != nil {
+				return fmt.Errorf("workload: %s: failed to encode score file: %w", w.Name(), err)
+			}
+			if toStdout {
+				if i > 0 {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), "---")
+				}
+				_, _ = cmd.OutOrStdout().Write(raw)
+				continue
+			}
+			path := filepath.Join(outputDir, "score-"+w.Name()+".yaml")
+			if _, err := os.Stat(path); err == nil && !overwrite {
+				return fmt.Errorf("score file '%s' already exists, use --%s to replace it", path, importCmdOverwriteFlag)
+			} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to check for existing score file: %w", err)
+			}
+			if err := os.WriteFile(path, raw, 0644); err != nil {
+				return fmt.Errorf("failed to write score file: %w", err)
+			}
+			slog.Info("Wrote Score file", "workload", w.Name(), "file", path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.Flags().String(importCmdFromFlag, importFromAvassa, "The format of the input files: avassa or compose")
+	importCmd.Flags().String(importCmdOutputDirFlag, "", "The directory to write the score files to, defaults to the current directory")
+	importCmd.Flags().Bool(importCmdStdoutFlag, false, "Print the score files to stdout instead, separated by ---")
+	importCmd.Flags().Bool(importCmdOverwriteFlag, false, "Replace existing score files")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,494 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportRoundTrip(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  labels:
+    tier: edge
+  annotations:
+    avassa.io/version: "2.1"
+    avassa.replicas: "3"
+    avassa.share-pid-namespace: "true"
+    avassa.network: shared
+    avassa.on-mutable-variable-change: restart-application
+    avassa.io/variable.REGION: north
+    avassa.containers.main.probes.liveness.period: 5s
+    avassa.containers.main.probes.startup.from: liveness
+    avassa.containers.main.probes.startup.failure-threshold: "30"
+    avassa.containers.sidecar.probes.readiness.tcp-port: "9000"
+containers:
+  main:
+    image: busybox
+    command: [sh, -c, "echo $${REGION}"]
+    variables:
+      AREA: $${REGION}
+    livenessProbe:
+      httpGet:
+        scheme: HTTPS
+        path: /live
+        port: 8443
+        httpHeaders:
+          - name: X-Probe
+            value: "1"
+    readinessProbe:
+      exec:
+        command: [cat, /tmp/ready]
+    x-avassa:
+      approle: example
+  sidecar:
+    image: envoy
+x-avassa:
+  custom: value
+  service:
+    name: custom-service
+`), 0644))
+
+	generated, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "score.yaml"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile("example.app.yaml", []byte(generated), 0644))
+	require.NoError(t, os.Mkdir("imported", 0755))
+	_, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--output-dir", "imported", "example.app.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, "", stderr)
+
+	regenerated, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "imported/score-example.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, generated, regenerated)
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--output-dir", "imported", "example.app.yaml"})
+	assert.EqualError(t, err, "score file 'imported/score-example.yaml' already exists, use --overwrite to replace it")
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--output-dir", "imported", "--overwrite", "example.app.yaml"})
+	assert.NoError(t, err)
+}
+
+func TestImportRoundTripWithFiles(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+containers:
+  main:
+    image: busybox
+    files:
+      /etc/app.conf:
+        content: |
+          name = ${metadata.name}
+        mode: "0600"
+      /etc/conf.d/app.conf:
+        content: user = $${USERNAME}
+      /usr/local/bin/start.sh:
+        content: |
+          echo "starting in ${HOME}"
+        mode: "0755"
+        noExpand: true
+    x-avassa:
+      mounts:
+        - volume-name: data
+          mount-path: /data
+x-avassa:
+  service:
+    volumes:
+      - name: data
+        ephemeral-volume:
+          size: 1 GB
+`), 0644))
+
+	generated, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "score.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, "", stderr)
+	assert.Equal(t, `---
+name: example
+services:
+    - name: example-service
+      mode: replicated
+      replicas: 1
+      share-pid-namespace: false
+      volumes:
+        - name: data
+          ephemeral-volume:
+            size: 1 GB
+        - name: main-files
+          config-map:
+            items:
+                - name: app.conf
+                  data: |
+                    name = example
+                  file-mode: "600"
+                - name: app.conf-2
+                  data: user = ${USERNAME}
+                - name: start.sh
+                  data-verbatim: |
+                    echo "starting in ${HOME}"
+                  file-mode: "755"
+      containers:
+        - name: main
+          image: busybox
+          container-log-size: 100 MB
+          shutdown-timeout: 10s
+          mounts:
+            - volume-name: data
+              mount-path: /data
+            - volume-name: main-files
+              files:
+                - name: app.conf
+                  mount-path: /etc/app.conf
+                - name: app.conf-2
+                  mount-path: /etc/conf.d/app.conf
+                - name: start.sh
+                  mount-path: /usr/local/bin/start.sh
+on-mutable-variable-change: restart-service-instance
+`, generated)
+
+	require.NoError(t, os.WriteFile("example.app.yaml", []byte(generated), 0644))
+	imported, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--stdout", "example.app.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, "", stderr)
+	assert.Contains(t, imported, `        files:
+            /etc/app.conf:
+                content: |
+                    name = example
+                mode: "0600"
+            /etc/conf.d/app.conf:
+                content: user = $${USERNAME}
+            /usr/local/bin/start.sh:
+                content: |
+                    echo "starting in ${HOME}"
+                mode: "0755"
+                noExpand: true
+`)
+	require.NoError(t, os.WriteFile("score-imported.yaml", []byte(imported), 0644))
+	regenerated, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "score-imported.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, generated, regenerated)
+
+	// a volume added through x-avassa may not reuse the name of a files volume
+	require.NoError(t, os.WriteFile("score.yaml", []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+containers:
+  main:
+    image: busybox
+    files:
+      /etc/app.conf:
+        content: hello
+x-avassa:
+  service:
+    volumes:
+      - name: main-files
+        ephemeral-volume:
+          size: 1 GB
+`), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "score.yaml"})
+	assert.EqualError(t, err, "failed to convert workloads: workload: example: volumes: duplicate volume name 'main-files'")
+}
+
+func TestImportAvassaApplication(t *testing.T) {
+	_ = changeToTempDir(t)
+	require.NoError(t, os.WriteFile("app.yaml", []byte(`
+name: theater
+version: "1.0"
+services:
+  - name: visitors
+    mode: replicated
+    replicas: 1
+    variables:
+      - name: USERNAME
+        value-from-vault-secret:
+          vault: operations
+          secret: credentials
+          key: username
+    volumes:
+      - name: config
+        config-map:
+          items:
+            - name: app.conf
+              data: |
+                user = ${USERNAME}
+    containers:
+      - name: visitors
+        image: visitor-counter:v1.0
+        container-log-size: 100 MB
+        mounts:
+          - volume-name: config
+            files:
+              - name: app.conf
+                mount-path: /etc/app.conf
+        env:
+          USERNAME: ${USERNAME}
+        probes:
+          liveness:
+            tcp:
+              port: 10000
+            period: 10s
+  - name: display
+    mode: one-per-matching-host
+    containers:
+      - name: display
+        image: display:v1.0
+        cmd: [display, --user, "${USERNAME}"]
+        shutdown-timeout: 30s
+`), 0644))
+
+	stdout, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--stdout", "app.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, "warning: workload: theater: services: the application has 2 services, each becomes a workload and application of its own\n", stderr)
+	assert.Equal(t, `apiVersion: score.dev/v1b1
+metadata:
+    annotations:
+        avassa.containers.visitors.probes.liveness.period: 10s
+        avassa.containers.visitors.probes.liveness.tcp-port: "10000"
+        avassa.io/version: "1.0"
+    name: theater-visitors
+containers:
+    visitors:
+        image: visitor-counter:v1.0
+        variables:
+            USERNAME: $${USERNAME}
+        files:
+            /etc/app.conf:
+                content: |
+                    user = $${USERNAME}
+x-avassa:
+    service:
+        name: visitors
+        variables:
+            - name: USERNAME
+              value-from-vault-secret:
+                key: username
+                secret: credentials
+                vault: operations
+---
+apiVersion: score.dev/v1b1
+metadata:
+    annotations:
+        avassa.io/mode: one-per-matching-host
+        avassa.io/version: "1.0"
+    name: theater-display
+containers:
+    display:
+        image: display:v1.0
+        command:
+            - display
+            - --user
+            - $${USERNAME}
+        x-avassa:
+            shutdown-timeout: 30s
+x-avassa:
+    service:
+        name: display
+`, stdout)
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--from", "helm", "app.yaml"})
+	assert.EqualError(t, err, "--from: unsupported source 'helm', expected avassa or compose")
+
+	require.NoError(t, os.WriteFile("broken.yaml", []byte("name: broken\n---\nname: other\nservices: []\n"), 0644))
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--stdout", "broken.yaml"})
+	assert.EqualError(t, err, "failed to import: broken.yaml: document 0: application: broken: services: expected a non-empty list")
+}
+
+func TestImportCompose(t *testing.T) {
+	_ = changeToTempDir(t)
+	_, _, err := executeAndResetCommand(context.Background(), rootCmd, []string{"init"})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove("score.yaml"))
+	require.NoError(t, os.WriteFile("docker-compose.yaml", []byte(`
+services:
+  web:
+    image: nginx:1.27
+    entrypoint: /docker-entrypoint.sh
+    command: ["nginx", "-g", "daemon off;"]
+    ports:
+      - "8080:80"
+      - 127.0.0.1:9000:9000/udp
+      - target: 443
+        published: 8443
+    environment:
+      - UPSTREAM=api:3000
+      - HOME_DIR=$${HOME}
+      - IMAGE_TAG=${TAG:-latest}
+      - TOKEN
+    volumes:
+      - static:/usr/share/nginx/html:ro
+      - ./nginx.conf:/etc/nginx/nginx.conf
+    healthcheck:
+      test: ["CMD-SHELL", "curl -f http://localhost/ || test -f $$HOME/ready"]
+      interval: 30s
+      timeout: 500ms
+      retries: 3
+    depends_on:
+      - api
+    restart: always
+    privileged: true
+  api:
+    build: ./api
+    environment:
+      LOG_LEVEL: debug
+    healthcheck:
+      test: ["CMD", "wget", "-q", "-O", "$${HOME}/health", "localhost:3000/health"]
+      start_period: 1m30s
+`), 0644))
+
+	_, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"import", "--from", "compose", "docker-compose.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, `warning: workload: api: containers.api.image: the service is built from source, set the image with --image when generating
+warning: workload: web: containers.web: the compose key 'privileged' is not supported and was dropped
+warning: workload: web: containers.web.livenessProbe: the healthcheck timeout '500ms' is not a whole number of seconds and was dropped
+warning: workload: web: containers.web.variables.IMAGE_TAG: compose interpolation is not applied, the value is passed on as is
+warning: workload: web: containers.web.variables.TOKEN: the value is taken from the compose environment and was dropped
+warning: workload: web: containers.web.volumes: the named volume 'static' is kept as a volume resource that generate does not mount, add an Avassa volume and mount through x-avassa
+warning: workload: web: containers.web.volumes: the bind mount of '/etc/nginx/nginx.conf' has no Score equivalent and was dropped
+warning: workload: web: x-avassa.service.network.ingress-ip-per-instance: the published port 8080 is not supported, the container port 80 is exposed instead
+warning: workload: web: x-avassa.service.network.ingress-ip-per-instance: the host address 127.0.0.1 of port 9000 is not supported and was dropped
+warning: workload: web: x-avassa.service.network.ingress-ip-per-instance: the published port 8443 is not supported, the container port 443 is exposed instead
+`, stderr)
+
+	raw, err := os.ReadFile("score-web.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: score.dev/v1b1
+metadata:
+    annotations:
+        avassa.containers.web.probes.liveness.failure-threshold: "3"
+        avassa.containers.web.probes.liveness.period: 30s
+    name: web
+containers:
+    web:
+        image: nginx:1.27
+        command:
+            - /docker-entrypoint.sh
+        args:
+            - nginx
+            - -g
+            - daemon off;
+        variables:
+            HOME_DIR: $${HOME}
+            IMAGE_TAG: $${TAG:-latest}
+            UPSTREAM: api:3000
+        volumes:
+            /usr/share/nginx/html:
+                readOnly: true
+                source: ${resources.static}
+        livenessProbe:
+            exec:
+                command:
+                    - sh
+                    - -c
+                    - curl -f http://localhost/ || test -f $HOME/ready
+resources:
+    api:
+        type: service
+    static:
+        type: volume
+x-avassa:
+    service:
+        network:
+            ingress-ip-per-instance:
+                protocols:
+                    - name: tcp
+                      port-ranges: 80,443
+                    - name: udp
+                      port-ranges: "9000"
+`, string(raw))
+
+	// the imported workloads go straight into generate
+	stdout, stderr, err := executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "--stdout", "--image", "api=registry.example.com/api:1.0", "score-api.yaml", "score-web.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, "warning: workload: web: containers.web.volumes: volumes are not supported and were dropped\n", stderr)
+	assert.Equal(t, `---
+name: api
+services:
+    - name: api-service
+      mode: replicated
+      replicas: 1
+      share-pid-namespace: false
+      containers:
+        - name: api
+          image: registry.example.com/api:1.0
+          container-log-size: 100 MB
+          shutdown-timeout: 10s
+          mounts: []
+          env:
+            LOG_LEVEL: debug
+          probes:
+            liveness:
+                exec:
+                    cmd:
+                        - wget
+                        - -q
+                        - -O
+                        - ${HOME}/health
+                        - localhost:3000/health
+                initial-delay: 1m30s
+on-mutable-variable-change: restart-service-instance
+---
+name: web
+services:
+    - name: web-service
+      mode: replicated
+      replicas: 1
+      share-pid-namespace: false
+      containers:
+        - name: web
+          image: nginx:1.27
+          cmd:
+            - /docker-entrypoint.sh
+            - nginx
+            - -g
+            - daemon off;
+          container-log-size: 100 MB
+          shutdown-timeout: 10s
+          mounts: []
+          env:
+            HOME_DIR: ${HOME}
+            IMAGE_TAG: ${TAG:-latest}
+            UPSTREAM: api:3000
+          probes:
+            liveness:
+                exec:
+                    cmd:
+                        - sh
+                        - -c
+                        - curl -f http://localhost/ || test -f $HOME/ready
+                period: 30s
+                failure-threshold: 3
+      network:
+        ingress-ip-per-instance:
+            protocols:
+                - name: tcp
+                  port-ranges: 80,443
+                - name: udp
+                  port-ranges: "9000"
+on-mutable-variable-change: restart-service-instance
+`, stdout)
+}
@@ -26,6 +26,7 @@ import (
     "gopkg.in/yaml.v3"
 
     "github.com/score-spec/score-implementation-avassa/internal/state"
+    "github.com/score-spec/score-implementation-avassa/pkg/avassa"
 )
 
 const (
@@ -53,7 +54,7 @@ var initCmd = &cobra.Command{
 			sd = &state.StateDirectory{
 				Path: state.DefaultRelativeStateDirectory,
 				State: state.State{
-					Workloads:   map[string]framework.ScoreWorkloadState[state.WorkloadExtras]{},
+					Workloads:   map[string]framework.ScoreWorkloadState[avassa.Extensions]{},
 					Resources:   map[framework.ResourceUid]framework.ScoreResourceState[state.ResourceExtras]{},
 					SharedState: map[string]interface{}{},
 				},
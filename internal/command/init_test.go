@@ -25,6 +25,7 @@ import (
     "github.com/stretchr/testify/require"
 
     "github.com/score-spec/score-implementation-avassa/internal/state"
+    "github.com/score-spec/score-implementation-avassa/pkg/avassa"
 )
 
 func TestInitNominal(t *testing.T) {
@@ -45,7 +46,8 @@ func TestInitNominal(t *testing.T) {
     stdout, stderr, err = executeAndResetCommand(context.Background(), rootCmd, []string{"generate", "score.yaml"})
     assert.NoError(t, err)
     assert.Equal(t, ``, stdout)
-    assert.Equal(t, "", strings.TrimSpace(stderr))
+    // only the conversion warning for the sample's service ports, which Avassa has no equivalent for
+    assert.Equal(t, "warning: workload: example: service.ports: service ports are not supported and were dropped, expose them through x-avassa network settings instead", strings.TrimSpace(stderr))
 
 	sd, ok, err := state.LoadStateDirectory(".")
 	assert.NoError(t, err)
@@ -87,7 +89,7 @@ func TestInitNominal_run_twice(t *testing.T) {
 	assert.NoError(t, err)
 	if assert.True(t, ok) {
 		assert.Equal(t, state.DefaultRelativeStateDirectory, sd.Path)
-		assert.Equal(t, map[string]framework.ScoreWorkloadState[state.WorkloadExtras]{}, sd.State.Workloads)
+		assert.Equal(t, map[string]framework.ScoreWorkloadState[avassa.Extensions]{}, sd.State.Workloads)
 		assert.Equal(t, map[framework.ResourceUid]framework.ScoreResourceState[state.ResourceExtras]{}, sd.State.Resources)
 		assert.Equal(t, map[string]interface{}{}, sd.State.SharedState)
 	}
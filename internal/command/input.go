@@ -0,0 +1,190 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// stdinArg is the score file argument that reads from stdin.
+	stdinArg = "-"
+	// recursiveSuffix marks a directory argument in the style of go packages, such as ./services/...
+	recursiveSuffix = "/..."
+)
+
+// scoreDocument is a single Score workload read from the input.
+type scoreDocument struct {
+	// file is the score file the document was read from, or nil for stdin.
+	file *string
+	// index is the position of the document within the file, and count the number of documents in the file.
+	index, count int
+	raw          map[string]interface{}
+}
+
+// name returns the name of the input in messages. The document index is only added for files with several documents,
+// counting from 0.
+func (d scoreDocument) name() string {
+	name := "stdin"
+	if d.file != nil {
+		name = *d.file
+	}
+	if d.count > 1 {
+		return fmt.Sprintf("%s: document %d", name, d.index)
+	}
+	return name
+}
+
+// isScoreFileName reports whether a file found in a directory is a score file.
+func isScoreFileName(name string) bool {
+	return strings.HasPrefix(name, "score") && strings.HasSuffix(name, ".yaml")
+}
+
+// expandScoreFileArgs expands the score file arguments into a sorted list of files. Directories, including the
+// ./dir/... form, are searched recursively for score*.yaml files, skipping hidden directories such as the state
+// directory. Globs are matched like the shell would, with matching directories searched as well. Files and - for
+// stdin are kept as they are.
+func expandScoreFileArgs(args []string) ([]string, error) {
+	var out []string
+	for _, arg := range args {
+		if arg == stdinArg {
+			out = append(out, arg)
+			continue
+		}
+		matches := []string{strings.TrimSuffix(arg, recursiveSuffix)}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("invalid score file pattern '%s': %w", arg, err)
+			}
+			matches = slices.DeleteFunc(matches, func(match string) bool {
+				return isHiddenMatch(arg, match)
+			})
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no score files match '%s'", arg)
+			}
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				found, err := findScoreFiles(match)
+				if err != nil {
+					return nil, err
+				} else if len(found) == 0 && len(matches) == 1 {
+					return nil, fmt.Errorf("no score*.yaml files found in '%s'", match)
+				}
+				for _, file := range found {
+					out = append(out, filepath.Clean(file))
+				}
+			} else if strings.HasSuffix(arg, recursiveSuffix) {
+				return nil, fmt.Errorf("'%s' is not a directory", match)
+			} else {
+				// missing files are reported when they are read
+				out = append(out, filepath.Clean(match))
+			}
+		}
+	}
+	// paths are cleaned above so that ./a/score.yaml and a/score.yaml are not read twice
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// isHiddenMatch reports whether the glob match goes through a hidden file or directory that the pattern does not name
+// explicitly. The shell does not match these with *, filepath.Glob does.
+func isHiddenMatch(pattern, match string) bool {
+	patternParts := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	for i, part := range strings.Split(filepath.ToSlash(match), "/") {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." && i < len(patternParts) && !strings.HasPrefix(patternParts[i], ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// findScoreFiles returns the score*.yaml files within the directory and its subdirectories.
+func findScoreFiles(dir string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+		} else if isScoreFileName(d.Name()) {
+			out = append(out, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search '%s' for score files: %w", dir, err)
+	}
+	return out, nil
+}
+
+// readScoreDocuments reads the Score workloads of the argument, a file or - for stdin. There may be several documents
+// separated by ---, each is a workload of its own. Empty documents are skipped.
+func readScoreDocuments(arg string, stdin io.Reader) ([]scoreDocument, error) {
+	var file *string
+	name, r := "stdin", stdin
+	if arg != stdinArg {
+		raw, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input score file: %s: %w", arg, err)
+		}
+		file, name, r = &arg, arg, bytes.NewReader(raw)
+	}
+
+	// decode the documents as nodes first, so that the document index is only added to errors when the file turns
+	// out to hold more than one workload
+	var nodes []*yaml.Node
+	var indexes []int
+	dec := yaml.NewDecoder(r)
+	for index := 0; ; index++ {
+		node := new(yaml.Node)
+		if err := dec.Decode(node); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			// the file holds several documents when a later one fails, so the index is added as in scoreDocument.name
+			if index > 0 {
+				return nil, fmt.Errorf("failed to decode input score file: %s: document %d: %w", name, index, err)
+			}
+			return nil, fmt.Errorf("failed to decode input score file: %s: %w", name, err)
+		} else if len(node.Content) > 0 && node.Content[0].Tag != "!!null" {
+			nodes, indexes = append(nodes, node), append(indexes, index)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("failed to decode input score file: %s: no documents", name)
+	}
+
+	out := make([]scoreDocument, len(nodes))
+	for i, node := range nodes {
+		out[i] = scoreDocument{file: file, index: indexes[i], count: len(nodes)}
+		if err := node.Decode(&out[i].raw); err != nil {
+			return nil, fmt.Errorf("failed to decode input score file: %s: %w", out[i].name(), err)
+		}
+	}
+	return out, nil
+}
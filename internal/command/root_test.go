@@ -50,6 +50,7 @@ func executeAndResetCommand(ctx context.Context, cmd *cobra.Command, args []stri
 		subCmd.SetContext(context.TODO())
 		subCmd.SilenceUsage = false
 		subCmd.Flags().VisitAll(func(f *pflag.Flag) {
+			f.Changed = false
 			if f.Value.Type() == "stringArray" {
 				_ = f.Value.(pflag.SliceValue).Replace(nil)
 			} else {
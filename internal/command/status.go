@@ -0,0 +1,121 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	statusCmdDeploymentFlag = "deployment"
+	statusCmdFormatFlag     = "format"
+)
+
+// siteStatus is a row of the status output.
+type siteStatus struct {
+	Site       string   `json:"site"`
+	Version    string   `json:"version"`
+	OperStatus string   `json:"oper-status"`
+	Unhealthy  []string `json:"unhealthy-containers"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status <app>",
+	Short: "Show the deployment state of an application on each site",
+	Long: `Query Control Tower for the application deployment of the application (<app>-deployment as created by deploy,
+unless --deployment is set) and for the service instances on each site it is deployed to. Containers that are not
+running or not ready are listed as <service-instance>/<container>.
+
+The Control Tower connection is configured like the deploy command.`,
+	Args: cobra.ExactArgs(1),
+	CompletionOptions: cobra.CompletionOptions{
+		HiddenDefaultCmd: true,
+	},
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		appName := args[0]
+
+		format, _ := cmd.Flags().GetString(statusCmdFormatFlag)
+		if format != "table" && format != outputFormatJSON {
+			return fmt.Errorf("--%s: unsupported format '%s', expected table or json", statusCmdFormatFlag, format)
+		}
+		deployment, _ := cmd.Flags().GetString(statusCmdDeploymentFlag)
+		if deployment == "" {
+			deployment = deploymentName(appName)
+		}
+
+		client, err := newAPIClient(cmd)
+		if err != nil {
+			return err
+		}
+		if err := client.Login(cmd.Context()); err != nil {
+			return err
+		}
+		deploymentState, ok, err := client.GetApplicationDeploymentState(cmd.Context(), deployment)
+		if err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("application deployment '%s' does not exist", deployment)
+		}
+
+		rows := make([]siteStatus, 0, len(deploymentState.Sites))
+		for _, site := range deploymentState.Sites {
+			row := siteStatus{Site: site.Name, Version: site.ApplicationVersion, OperStatus: "not-running", Unhealthy: []string{}}
+			siteState, ok, err := client.GetSiteApplicationState(cmd.Context(), site.Name, appName)
+			if err != nil {
+				return err
+			} else if ok {
+				row.OperStatus = siteState.OperStatus
+				for _, instance := range siteState.ServiceInstances {
+					for _, c := range instance.Containers {
+						if !c.Healthy() {
+							row.Unhealthy = append(row.Unhealthy, instance.Name+"/"+c.Name)
+						}
+					}
+				}
+			}
+			rows = append(rows, row)
+		}
+
+		if format == outputFormatJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(rows)
+		}
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "SITE\tVERSION\tOPER-STATUS\tUNHEALTHY")
+		for _, row := range rows {
+			unhealthy := strings.Join(row.Unhealthy, ",")
+			if unhealthy == "" {
+				unhealthy = "-"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.Site, row.Version, row.OperStatus, unhealthy)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	addAPIFlags(statusCmd)
+	statusCmd.Flags().String(statusCmdDeploymentFlag, "", "The application deployment to report on, defaults to <app>-deployment")
+	statusCmd.Flags().String(statusCmdFormatFlag, "table", "The output format: table or json")
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/score-spec/score-implementation-avassa/internal/controltower"
+)
+
+func TestStatus(t *testing.T) {
+	_ = changeToTempDir(t)
+	server := newFakeControlTower(t)
+	t.Setenv(controltower.EnvUsername, "admin")
+	t.Setenv(controltower.EnvPassword, "secret")
+	server.state["/v1/state/application-deployments/example-deployment"] = map[string]interface{}{
+		"name":        "example-deployment",
+		"application": "example",
+		"sites": []interface{}{
+			map[string]interface{}{"name": "stockholm", "application-version": "1.2"},
+			map[string]interface{}{"name": "gothenburg", "application-version": "1.1"},
+			map[string]interface{}{"name": "malmo", "application-version": "1.2"},
+		},
+	}
+	server.state["/v1/state/sites/stockholm/applications/example"] = map[string]interface{}{
+		"name":        "example",
+		"oper-status": "running",
+		"service-instances": []interface{}{
+			map[string]interface{}{"name": "example-service-1", "oper-status": "running", "containers": []interface{}{
+				map[string]interface{}{"name": "main", "oper-status": "running", "ready": true},
+			}},
+		},
+	}
+	server.state["/v1/state/sites/gothenburg/applications/example"] = map[string]interface{}{
+		"name":        "example",
+		"oper-status": "degraded",
+		"service-instances": []interface{}{
+			map[string]interface{}{"name": "example-service-1", "oper-status": "running", "containers": []interface{}{
+				map[string]interface{}{"name": "main", "oper-status": "running", "ready": false},
+				map[string]interface{}{"name": "sidecar", "oper-status": "running"},
+			}},
+			map[string]interface{}{"name": "example-service-2", "oper-status": "failed", "containers": []interface{}{
+				map[string]interface{}{"name": "main", "oper-status": "exited"},
+			}},
+		},
+	}
+	args := []string{"status", "--url", server.URL, "--ca-cert", server.caFile(t), "example"}
+
+	stdout, _, err := executeAndResetCommand(context.Background(), rootCmd, args)
+	require.NoError(t, err)
+	assert.Equal(t, `SITE        VERSION  OPER-STATUS  UNHEALTHY
+stockholm   1.2      running      -
+gothenburg  1.1      degraded     example-service-1/main,example-service-2/main
+malmo       1.2      not-running  -
+`, stdout)
+
+	stdout, _, err = executeAndResetCommand(context.Background(), rootCmd, append(args, "--format", "json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+  {"site": "stockholm", "version": "1.2", "oper-status": "running", "unhealthy-containers": []},
+  {"site": "gothenburg", "version": "1.1", "oper-status": "degraded", "unhealthy-containers": ["example-service-1/main", "example-service-2/main"]},
+  {"site": "malmo", "version": "1.2", "oper-status": "not-running", "unhealthy-containers": []}
+]`, stdout)
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, append(args, "--deployment", "other"))
+	assert.EqualError(t, err, "application deployment 'other' does not exist")
+
+	_, _, err = executeAndResetCommand(context.Background(), rootCmd, append(args, "--format", "yaml"))
+	assert.EqualError(t, err, "--format: unsupported format 'yaml', expected table or json")
+}
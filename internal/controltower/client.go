@@ -0,0 +1,216 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controltower is a small client for the Avassa Control Tower REST API, covering login and the application
+// and application-deployment resources.
+package controltower
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Environment variables read by ConfigFromEnv.
+const (
+	EnvURL      = "AVASSA_URL"
+	EnvUsername = "AVASSA_USERNAME"
+	EnvPassword = "AVASSA_PASSWORD"
+	EnvRoleID   = "AVASSA_ROLE_ID"
+	EnvSecretID = "AVASSA_SECRET_ID"
+	EnvCACert   = "AVASSA_CA_CERT"
+)
+
+// Config holds the connection settings for Control Tower. Either Username and Password or RoleID and SecretID (an
+// approle) must be set.
+type Config struct {
+	URL      string
+	Username string
+	Password string
+	RoleID   string
+	SecretID string
+	// CACertFile is a PEM file of the certificate authorities to trust instead of the system roots.
+	CACertFile string
+	// InsecureSkipVerify disables verification of the server certificate.
+	InsecureSkipVerify bool
+}
+
+// ConfigFromEnv returns the connection settings from the AVASSA_* environment variables.
+func ConfigFromEnv() Config {
+	return Config{
+		URL:        os.Getenv(EnvURL),
+		Username:   os.Getenv(EnvUsername),
+		Password:   os.Getenv(EnvPassword),
+		RoleID:     os.Getenv(EnvRoleID),
+		SecretID:   os.Getenv(EnvSecretID),
+		CACertFile: os.Getenv(EnvCACert),
+	}
+}
+
+// Client talks to the Control Tower REST API. Call Login before any other method.
+type Client struct {
+	config Config
+	base   *url.URL
+	http   *http.Client
+	token  string
+}
+
+// NewClient validates the config and builds a client, without contacting the server.
+func NewClient(config Config) (*Client, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the control tower url is not set, use --url or %s", EnvURL)
+	}
+	base, err := url.Parse(strings.TrimSuffix(config.URL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("'%s' is not a valid control tower url", config.URL)
+	}
+	if (config.Username == "" || config.Password == "") && (config.RoleID == "" || config.SecretID == "") {
+		return nil, fmt.Errorf("no credentials, set %s and %s, or %s and %s", EnvUsername, EnvPassword, EnvRoleID, EnvSecretID)
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CACertFile != "" {
+		raw, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca certificate file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			return nil, fmt.Errorf("ca certificate file '%s' contains no PEM certificates", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{config: config, base: base, http: &http.Client{Transport: transport}}, nil
+}
+
+// URL returns the absolute url of the api path.
+func (c *Client) URL(path string) string {
+	return c.base.String() + path
+}
+
+// Login exchanges the credentials for a session token, using the approle when a role id is set.
+func (c *Client) Login(ctx context.Context) error {
+	path, body := "/v1/login", map[string]string{"username": c.config.Username, "password": c.config.Password}
+	if c.config.RoleID != "" {
+		path, body = "/v1/approle-login", map[string]string{"role-id": c.config.RoleID, "secret-id": c.config.SecretID}
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, path, body, &out); err != nil {
+		return fmt.Errorf("failed to log in: %w", err)
+	}
+	if out.Token == "" {
+		return fmt.Errorf("failed to log in: no token in response")
+	}
+	c.token = out.Token
+	return nil
+}
+
+// ApplicationPath is the api path of the named application.
+func ApplicationPath(name string) string {
+	return "/v1/config/applications/" + url.PathEscape(name)
+}
+
+// ApplicationDeploymentPath is the api path of the named application deployment.
+func ApplicationDeploymentPath(name string) string {
+	return "/v1/config/application-deployments/" + url.PathEscape(name)
+}
+
+// PutApplication creates or replaces the application and reports whether it was created.
+func (c *Client) PutApplication(ctx context.Context, app map[string]interface{}) (bool, error) {
+	name, _ := app["name"].(string)
+	status, err := c.do(ctx, http.MethodPut, ApplicationPath(name), app, nil)
+	if err != nil {
+		return false, fmt.Errorf("application: %s: %w", name, err)
+	}
+	return status == http.StatusCreated, nil
+}
+
+// PutApplicationDeployment creates or replaces the application deployment and reports whether it was created.
+func (c *Client) PutApplicationDeployment(ctx context.Context, deployment map[string]interface{}) (bool, error) {
+	name, _ := deployment["name"].(string)
+	status, err := c.do(ctx, http.MethodPut, ApplicationDeploymentPath(name), deployment, nil)
+	if err != nil {
+		return false, fmt.Errorf("application-deployment: %s: %w", name, err)
+	}
+	return status == http.StatusCreated, nil
+}
+
+// GetApplication returns the application spec, or false if there is no application with that name.
+func (c *Client) GetApplication(ctx context.Context, name string) (map[string]interface{}, bool, error) {
+	var out map[string]interface{}
+	status, err := c.do(ctx, http.MethodGet, ApplicationPath(name), nil, &out)
+	if status == http.StatusNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("application: %s: %w", name, err)
+	}
+	return out, true, nil
+}
+
+// do sends the request with the body encoded as json and decodes a json response into out when it is not nil. The
+// status code is returned even when the request failed with a http error.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.URL(path), reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("%s %s: failed to read response: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(raw))
+		if len(msg) > 200 {
+			msg = msg[:200] + "..."
+		}
+		return resp.StatusCode, fmt.Errorf("%s %s: unexpected status %d: %s", method, path, resp.StatusCode, msg)
+	}
+	if out != nil && len(bytes.TrimSpace(raw)) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
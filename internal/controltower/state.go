@@ -0,0 +1,95 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controltower
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ApplicationDeploymentState is the operational state of an application deployment.
+type ApplicationDeploymentState struct {
+	Name        string                           `json:"name"`
+	Application string                           `json:"application"`
+	Sites       []ApplicationDeploymentSiteState `json:"sites"`
+}
+
+// ApplicationDeploymentSiteState is the deployment state on one site.
+type ApplicationDeploymentSiteState struct {
+	Name               string `json:"name"`
+	ApplicationVersion string `json:"application-version"`
+}
+
+// SiteApplicationState is the state of an application on a single site.
+type SiteApplicationState struct {
+	Name             string                 `json:"name"`
+	OperStatus       string                 `json:"oper-status"`
+	ServiceInstances []ServiceInstanceState `json:"service-instances"`
+}
+
+// ServiceInstanceState is the state of one instance of a service.
+type ServiceInstanceState struct {
+	Name       string           `json:"name"`
+	OperStatus string           `json:"oper-status"`
+	Containers []ContainerState `json:"containers"`
+}
+
+// ContainerState is the state of a container in a service instance.
+type ContainerState struct {
+	Name       string `json:"name"`
+	OperStatus string `json:"oper-status"`
+	Ready      *bool  `json:"ready,omitempty"`
+}
+
+// Healthy reports whether the container is running and not reported as unready.
+func (c ContainerState) Healthy() bool {
+	return c.OperStatus == "running" && (c.Ready == nil || *c.Ready)
+}
+
+// ApplicationDeploymentStatePath is the api path of the state of the named application deployment.
+func ApplicationDeploymentStatePath(name string) string {
+	return "/v1/state/application-deployments/" + url.PathEscape(name)
+}
+
+// SiteApplicationStatePath is the api path of the state of the application on the site.
+func SiteApplicationStatePath(site, app string) string {
+	return "/v1/state/sites/" + url.PathEscape(site) + "/applications/" + url.PathEscape(app)
+}
+
+// GetApplicationDeploymentState returns the state of the application deployment, or false if it does not exist.
+func (c *Client) GetApplicationDeploymentState(ctx context.Context, name string) (*ApplicationDeploymentState, bool, error) {
+	var out ApplicationDeploymentState
+	status, err := c.do(ctx, http.MethodGet, ApplicationDeploymentStatePath(name), nil, &out)
+	if status == http.StatusNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("application-deployment: %s: %w", name, err)
+	}
+	return &out, true, nil
+}
+
+// GetSiteApplicationState returns the state of the application on the site, or false if it is not running there.
+func (c *Client) GetSiteApplicationState(ctx context.Context, site, app string) (*SiteApplicationState, bool, error) {
+	var out SiteApplicationState
+	status, err := c.do(ctx, http.MethodGet, SiteApplicationStatePath(site, app), nil, &out)
+	if status == http.StatusNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("site: %s: application: %s: %w", site, app, err)
+	}
+	return &out, true, nil
+}
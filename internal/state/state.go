@@ -18,23 +18,31 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/score-spec/score-go/framework"
 	"gopkg.in/yaml.v3"
+
+	"github.com/score-spec/score-implementation-avassa/pkg/avassa"
 )
 
 const (
     DefaultRelativeStateDirectory = ".score-implementation-avassa"
     FileName                      = "state.yaml"
+    ConfigFileName                = "config.yaml"
 )
 
-type WorkloadExtras struct{}
-
-type ResourceExtras struct{}
+// ResourceExtras holds the implementation specific fields that older versions recorded for each resource. It is only
+// kept so that their state files still decode, the resources are dropped when the state is loaded.
+type ResourceExtras struct {
+	// SecretOutputs lists the output keys of the resource that hold secrets. Values derived from these are never
+	// hoisted into plain service variables.
+	SecretOutputs []string `yaml:"secret_outputs,omitempty"`
+}
 
-type State = framework.State[framework.NoExtras, WorkloadExtras, ResourceExtras]
+type State = framework.State[framework.NoExtras, avassa.Extensions, ResourceExtras]
 
 // The StateDirectory holds the local state of the project, including any configuration, extensions,
 // plugins, or resource provisioning state when possible.
@@ -43,6 +51,8 @@ type StateDirectory struct {
 	Path string
 	// The current state file
 	State State
+	// The project configuration, this is never written back by Persist
+	Config avassa.Config
 }
 
 // Persist ensures that the directory is created and that the current config file has been written with the latest settings.
@@ -86,5 +96,21 @@ func LoadStateDirectory(directory string) (*StateDirectory, bool, error) {
 	if err := dec.Decode(&out); err != nil {
 		return nil, true, fmt.Errorf("state file couldn't be decoded: %w", err)
 	}
-	return &StateDirectory{d, out}, true, nil
+	// The converter primes and provisions the resources of every conversion itself, so resources recorded by older
+	// versions are never read and are dropped rather than written back.
+	out.Resources = map[framework.ResourceUid]framework.ScoreResourceState[ResourceExtras]{}
+
+	var config avassa.Config
+	if content, err := os.ReadFile(filepath.Join(d, ConfigFileName)); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, true, fmt.Errorf("config file couldn't be read: %w", err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, true, fmt.Errorf("config file couldn't be decoded: %w", err)
+		}
+	}
+	return &StateDirectory{Path: d, State: out, Config: config}, true, nil
 }
@@ -0,0 +1,385 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	scoretypes "github.com/score-spec/score-go/types"
+	"gopkg.in/yaml.v3"
+)
+
+// composeIngressPath is the path of the ingress that compose ports are mapped onto, used in warnings.
+const composeIngressPath = "x-avassa.service.network.ingress-ip-per-instance"
+
+// composeIgnoredKeys are compose service keys that only matter to a local docker engine and are dropped silently.
+var composeIgnoredKeys = []string{"container_name", "restart", "networks", "hostname", "stdin_open", "tty"}
+
+// ImportCompose converts every service of a docker compose file into a Score workload with a single container named
+// after the service. Ports become an x-avassa ingress, environment the container variables, named volumes volume
+// resources, the healthcheck the liveness probe and depends_on resources of type service. Anything else, and named
+// volumes as generate does not mount them, is reported as a warning.
+func ImportCompose(raw []byte, diags *Diagnostics) ([]ScoreWorkload, error) {
+	var compose struct {
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(raw, &compose); err != nil {
+		return nil, fmt.Errorf("failed to decode compose file: %w", err)
+	} else if len(compose.Services) == 0 {
+		return nil, fmt.Errorf("services: expected at least one compose service")
+	}
+
+	out := make([]ScoreWorkload, 0, len(compose.Services))
+	for _, name := range slices.Sorted(maps.Keys(compose.Services)) {
+		workload, err := importComposeService(name, compose.Services[name], diags)
+		if err != nil {
+			return nil, fmt.Errorf("services: %s: %w", name, err)
+		}
+		out = append(out, workload)
+	}
+	return out, nil
+}
+
+func importComposeService(name string, svc map[string]interface{}, diags *Diagnostics) (ScoreWorkload, error) {
+	workloadName := sanitizeName(name)
+	containerName := workloadName
+	prefix := "containers." + containerName
+	workload := ScoreWorkload{
+		APIVersion: scoreAPIVersion,
+		Metadata:   map[string]interface{}{"name": workloadName},
+	}
+	container := ScoreContainer{Image: asString(svc["image"])}
+	annotations := map[string]interface{}{}
+
+	for _, key := range slices.Sorted(maps.Keys(svc)) {
+		value := svc[key]
+		var err error
+		switch key {
+		case "image":
+		case "build":
+			if container.Image == "" {
+				container.Image = "."
+				diags.Warn(workloadName, prefix+".image", "the service is built from source, set the image with --image when generating")
+			}
+		case "entrypoint":
+			container.Command, err = composeCommand(value)
+		case "command":
+			container.Args, err = composeCommand(value)
+		case "environment":
+			container.Variables, err = composeEnvironment(value, workloadName, prefix, diags)
+		case "ports":
+			if protocols, err := composePorts(value, workloadName, diags); err != nil {
+				return workload, fmt.Errorf("ports: %w", err)
+			} else if len(protocols) > 0 {
+				workload.Avassa = map[string]interface{}{avassaServiceExtensionKey: map[string]interface{}{
+					"network": map[string]interface{}{"ingress-ip-per-instance": map[string]interface{}{"protocols": protocols}},
+				}}
+			}
+		case "volumes":
+			err = importComposeVolumes(&workload, &container, value, workloadName, prefix, diags)
+		case "healthcheck":
+			container.LivenessProbe, err = composeHealthcheck(value, containerName, annotations, workloadName, prefix, diags)
+		case "depends_on":
+			err = importComposeDependencies(&workload, value)
+		default:
+			if !slices.Contains(composeIgnoredKeys, key) {
+				diags.Warn(workloadName, prefix, "the compose key '%s' is not supported and was dropped", key)
+			}
+		}
+		if err != nil {
+			return workload, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if container.Image == "" {
+		return workload, fmt.Errorf("image: either image or build must be set")
+	}
+	if len(annotations) > 0 {
+		workload.Metadata["annotations"] = annotations
+	}
+	workload.Containers = map[string]ScoreContainer{containerName: container}
+	return workload, nil
+}
+
+// composeCommand converts a command given as a list or as a string split on white space. Dollars are escaped so that
+// Score passes them on.
+func composeCommand(v interface{}) ([]string, error) {
+	var out []string
+	switch t := v.(type) {
+	case string:
+		out = strings.Fields(t)
+	case []interface{}:
+		for _, part := range t {
+			out = append(out, asString(part))
+		}
+	default:
+		return nil, fmt.Errorf("expected a string or a list")
+	}
+	for i := range out {
+		literal, _ := composeLiteral(out[i])
+		out[i] = escapePlaceholders(literal)
+	}
+	return out, nil
+}
+
+// composeLiteral unescapes the $$ of a compose value and reports whether the value has compose variables that would
+// have been interpolated from the environment.
+func composeLiteral(v string) (string, bool) {
+	var out strings.Builder
+	interpolated := false
+	for i := 0; i < len(v); i++ {
+		if v[i] == '$' {
+			if i+1 < len(v) && v[i+1] == '$' {
+				i++
+			} else {
+				interpolated = true
+			}
+		}
+		out.WriteByte(v[i])
+	}
+	return out.String(), interpolated
+}
+
+// composeEnvironment converts the environment map or list of KEY=VALUE entries. Entries without a value take it from
+// the shell running compose, which Score can't express.
+func composeEnvironment(v interface{}, workloadName, prefix string, diags *Diagnostics) (map[string]string, error) {
+	out := map[string]string{}
+	add := func(key string, value interface{}, hasValue bool) {
+		if !hasValue || value == nil {
+			diags.Warn(workloadName, prefix+".variables."+key, "the value is taken from the compose environment and was dropped")
+			return
+		}
+		s, interpolated := composeLiteral(asString(value))
+		if interpolated {
+			diags.Warn(workloadName, prefix+".variables."+key, "compose interpolation is not applied, the value is passed on as is")
+		}
+		out[key] = escapePlaceholders(s)
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			add(key, value, true)
+		}
+	case []interface{}:
+		for _, entry := range t {
+			key, value, ok := strings.Cut(asString(entry), "=")
+			add(key, value, ok)
+		}
+	default:
+		return nil, fmt.Errorf("expected a map or a list")
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// composePorts converts the short ([ip:][published:]target[/protocol]) and long port syntax into the protocols of an
+// Avassa ingress-ip-per-instance, listing the container ports per protocol. The instance ingress address exposes the
+// container ports as they are, so published ports that differ and host addresses are dropped with a warning.
+func composePorts(v interface{}, workloadName string, diags *Diagnostics) ([]interface{}, error) {
+	entries, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list")
+	}
+	ports := map[string][]int{}
+	for _, entry := range entries {
+		var hostIP, published, target, protocol string
+		switch t := entry.(type) {
+		case map[string]interface{}:
+			hostIP, published, target, protocol = asString(t["host_ip"]), asString(t["published"]), asString(t["target"]), asString(t["protocol"])
+		default:
+			spec := asString(t)
+			spec, protocol, _ = strings.Cut(spec, "/")
+			parts := strings.Split(spec, ":")
+			target = parts[len(parts)-1]
+			if len(parts) > 1 {
+				published = parts[len(parts)-2]
+			}
+			if len(parts) > 2 {
+				hostIP = strings.Join(parts[:len(parts)-2], ":")
+			}
+		}
+		targetPort, err := strconv.Atoi(target)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' is not a single port, port ranges are not supported", entry)
+		}
+		if published != "" && published != target {
+			if _, err := strconv.Atoi(published); err != nil {
+				return nil, fmt.Errorf("'%v' is not a single port, port ranges are not supported", entry)
+			}
+			diags.Warn(workloadName, composeIngressPath, "the published port %s is not supported, the container port %d is exposed instead", published, targetPort)
+		}
+		if hostIP != "" {
+			diags.Warn(workloadName, composeIngressPath, "the host address %s of port %d is not supported and was dropped", hostIP, targetPort)
+		}
+		protocol = strings.ToLower(protocol)
+		switch protocol {
+		case "":
+			protocol = "tcp"
+		case "tcp", "udp":
+		default:
+			return nil, fmt.Errorf("'%v' has the unsupported protocol '%s'", entry, protocol)
+		}
+		if !slices.Contains(ports[protocol], targetPort) {
+			ports[protocol] = append(ports[protocol], targetPort)
+		}
+	}
+	var out []interface{}
+	for _, protocol := range slices.Sorted(maps.Keys(ports)) {
+		slices.Sort(ports[protocol])
+		ranges := make([]string, len(ports[protocol]))
+		for i, port := range ports[protocol] {
+			ranges[i] = strconv.Itoa(port)
+		}
+		out = append(out, map[string]interface{}{"name": protocol, "port-ranges": strings.Join(ranges, ",")})
+	}
+	return out, nil
+}
+
+// importComposeVolumes mounts named volumes from volume resources of the same name, with a warning as generate does
+// not mount volumes. Bind mounts and anonymous volumes have no Score equivalent and are dropped.
+func importComposeVolumes(workload *ScoreWorkload, container *ScoreContainer, v interface{}, workloadName, prefix string, diags *Diagnostics) error {
+	entries, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected a list")
+	}
+	for _, entry := range entries {
+		var volumeType, source, target string
+		var readOnly bool
+		switch t := entry.(type) {
+		case map[string]interface{}:
+			volumeType, source, target = asString(t["type"]), asString(t["source"]), asString(t["target"])
+			readOnly = asBool(t["read_only"], false)
+		default:
+			parts := strings.Split(asString(t), ":")
+			switch len(parts) {
+			case 1:
+				target = parts[0]
+			default:
+				source, target = parts[0], parts[1]
+				readOnly = len(parts) > 2 && slices.Contains(strings.Split(parts[2], ","), "ro")
+			}
+			volumeType = "volume"
+			if strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~") {
+				volumeType = "bind"
+			}
+		}
+		if target == "" {
+			return fmt.Errorf("'%v' has no target path", entry)
+		} else if volumeType != "volume" || source == "" {
+			if volumeType == "volume" {
+				volumeType = "anonymous volume"
+			}
+			diags.Warn(workloadName, prefix+".volumes", "the %s mount of '%s' has no Score equivalent and was dropped", volumeType, target)
+			continue
+		}
+		resName := sanitizeName(source)
+		diags.Warn(workloadName, prefix+".volumes", "the named volume '%s' is kept as a volume resource that generate does not mount, add an Avassa volume and mount through x-avassa", source)
+		if workload.Resources == nil {
+			workload.Resources = map[string]scoretypes.Resource{}
+		}
+		workload.Resources[resName] = scoretypes.Resource{Type: "volume"}
+		if container.Volumes == nil {
+			container.Volumes = map[string]scoretypes.ContainerVolume{}
+		}
+		volume := scoretypes.ContainerVolume{Source: "${resources." + resName + "}"}
+		if readOnly {
+			volume.ReadOnly = &readOnly
+		}
+		container.Volumes[target] = volume
+	}
+	return nil
+}
+
+// composeHealthcheck converts the healthcheck test into an exec liveness probe and its timing into probe annotations.
+// Compose $$ escapes in the test are unescaped like in commands.
+func composeHealthcheck(v interface{}, containerName string, annotations map[string]interface{}, workloadName, prefix string, diags *Diagnostics) (*scoretypes.ContainerProbe, error) {
+	hc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map")
+	} else if asBool(hc["disable"], false) {
+		return nil, nil
+	}
+	var command []string
+	switch t := hc["test"].(type) {
+	case string:
+		command = []string{"sh", "-c", t}
+	case []interface{}:
+		for _, part := range t {
+			command = append(command, asString(part))
+		}
+		if len(command) == 0 {
+			return nil, fmt.Errorf("test: expected a command")
+		}
+		switch command[0] {
+		case "NONE":
+			return nil, nil
+		case "CMD":
+			command = command[1:]
+		case "CMD-SHELL":
+			command = []string{"sh", "-c", strings.Join(command[1:], " ")}
+		}
+	default:
+		return nil, fmt.Errorf("test: expected a string or a list")
+	}
+	if len(command) == 0 {
+		return nil, fmt.Errorf("test: expected a command")
+	}
+	// probe commands are not expanded by Score, so only the compose escapes are undone
+	for i := range command {
+		command[i], _ = composeLiteral(command[i])
+	}
+
+	annotationPrefix := "avassa.containers." + containerName + ".probes." + probeLiveness + "."
+	for composeKey, key := range map[string]string{"interval": "period", "timeout": "timeout", "start_period": "initial-delay"} {
+		if raw, ok := hc[composeKey]; ok {
+			if d := asString(raw); validateDuration(d) != nil {
+				diags.Warn(workloadName, prefix+".livenessProbe", "the healthcheck %s '%s' is not a whole number of seconds and was dropped", composeKey, d)
+			} else {
+				annotations[annotationPrefix+key] = d
+			}
+		}
+	}
+	if raw, ok := hc["retries"]; ok {
+		annotations[annotationPrefix+"failure-threshold"] = asString(raw)
+	}
+	return &scoretypes.ContainerProbe{Exec: &scoretypes.ExecProbe{Command: command}}, nil
+}
+
+// importComposeDependencies adds a resource of type service for every service the workload depends on.
+func importComposeDependencies(workload *ScoreWorkload, v interface{}) error {
+	var names []string
+	switch t := v.(type) {
+	case []interface{}:
+		for _, name := range t {
+			names = append(names, asString(name))
+		}
+	case map[string]interface{}:
+		names = slices.Collect(maps.Keys(t))
+	default:
+		return fmt.Errorf("expected a list or a map")
+	}
+	for _, name := range names {
+		if workload.Resources == nil {
+			workload.Resources = map[string]scoretypes.Resource{}
+		}
+		workload.Resources[sanitizeName(name)] = scoretypes.Resource{Type: "service"}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"github.com/score-spec/score-go/framework"
+)
+
+// Config is the project wide conversion policy. The CLI reads it from the config file in its state directory.
+type Config struct {
+	// Security is the security policy applied to all generated containers.
+	Security SecurityPolicy `yaml:"security,omitempty"`
+	// Network is the network policy applied to all generated services.
+	Network NetworkPolicy `yaml:"network,omitempty"`
+	// Labels controls how Score labels are mapped to application labels.
+	Labels LabelPolicy `yaml:"labels,omitempty"`
+}
+
+// LabelPolicy controls the labels generated for every application.
+type LabelPolicy struct {
+	// Prefix is a DNS domain, for example example.com, that is added to Score labels without a prefix.
+	Prefix string `yaml:"prefix,omitempty"`
+}
+
+// SecurityPolicy controls which security sensitive container settings workloads may request.
+type SecurityPolicy struct {
+	// AllowedCapabilities lists the privileged capabilities that containers may request through
+	// additional-capabilities, for example net-admin.
+	AllowedCapabilities []string `yaml:"allowed-capabilities,omitempty"`
+}
+
+// NetworkPolicy controls the network access generated for every service.
+type NetworkPolicy struct {
+	// OutboundDefaultAction opts all services in to outbound access rules with the given default action, allow or
+	// deny. Workloads may override it with the avassa.outbound-access.default-action annotation.
+	OutboundDefaultAction string `yaml:"outbound-default-action,omitempty"`
+	// UpstreamBandwidthPerHost is the default outbound bandwidth limit of each application per host, for example
+	// 10 Mbit/s. Workloads may override it with the avassa.resources.network.upstream-bandwidth-per-host annotation.
+	UpstreamBandwidthPerHost string `yaml:"upstream-bandwidth-per-host,omitempty"`
+	// DownstreamBandwidthPerHost is the default inbound bandwidth limit of each application per host. Workloads may
+	// override it with the avassa.resources.network.downstream-bandwidth-per-host annotation.
+	DownstreamBandwidthPerHost string `yaml:"downstream-bandwidth-per-host,omitempty"`
+}
+
+// Extensions holds the x-avassa extension blocks that were removed from the Score workload before validation. The CLI
+// keeps them in its state file with the workload.
+type Extensions struct {
+	// Avassa is the top-level x-avassa block of the workload.
+	Avassa map[string]interface{} `yaml:"x_avassa,omitempty"`
+	// ContainerAvassa holds the x-avassa block of each container by container name.
+	ContainerAvassa map[string]map[string]interface{} `yaml:"x_avassa_containers,omitempty"`
+}
+
+// resourceExtras holds the fields recorded for each resource during a conversion.
+type resourceExtras struct {
+	// SecretOutputs lists the output keys of the resource that hold secrets. Values derived from these are never
+	// hoisted into plain service variables.
+	SecretOutputs []string
+}
+
+// projectState holds the workloads and resources of a single conversion.
+type projectState = framework.State[framework.NoExtras, Extensions, resourceExtras]
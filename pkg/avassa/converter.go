@@ -0,0 +1,365 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package avassa converts Score workloads into Avassa application specs, and Avassa applications or docker compose
+// files back into Score workloads. It is the library behind the score-implementation-avassa CLI and works entirely in
+// memory: it reads no state directory and, unless a file reader is configured, no files.
+package avassa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+
+	"github.com/score-spec/score-go/framework"
+	scoreloader "github.com/score-spec/score-go/loader"
+	scoreschema "github.com/score-spec/score-go/schema"
+	scoretypes "github.com/score-spec/score-go/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Workload is a Score workload to convert.
+type Workload struct {
+	// Spec is the Score workload.
+	Spec scoretypes.Workload
+	// Extensions are the x-avassa blocks removed from the workload before validation.
+	Extensions Extensions
+	// File is the path of the Score file, relative files[].source paths are resolved against its directory.
+	File string
+	// Reference marks a workload that is only given so that the other workloads can refer to it, for example in
+	// placement rules or through shared resources. No application is returned for it, and its warnings are neither
+	// recorded nor counted in strict mode.
+	Reference bool
+}
+
+// Application is a generated Avassa application. It marshals to yaml and json with the keys in the order of the Avassa
+// application reference.
+type Application struct {
+	// Workload is the name of the Score workload the application was generated from.
+	Workload string
+	// Name is the application name.
+	Name string
+	// Version is the application version, if one was set.
+	Version string
+	// Spec is the typed application spec.
+	Spec ApplicationSpec
+	// Manifest is the application spec as a map, it is what the application marshals to.
+	Manifest map[string]interface{}
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (a Application) MarshalYAML() (interface{}, error) {
+	return manifestNode(a.Manifest, ""), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a Application) MarshalJSON() ([]byte, error) {
+	return manifestJSON(a.Manifest)
+}
+
+var _ yaml.Marshaler = Application{}
+var _ json.Marshaler = Application{}
+
+// Resource is a resource of a workload to provision.
+type Resource struct {
+	// Uid identifies the resource, it holds the type, class and id.
+	Uid framework.ResourceUid
+	// SourceWorkload is the workload that declared the resource, or the first of them for shared resources.
+	SourceWorkload string
+	// Metadata is the resource metadata.
+	Metadata map[string]interface{}
+	// Params are the resource params with their placeholders substituted.
+	Params map[string]interface{}
+}
+
+// ProvisionedResource is the result of provisioning a resource.
+type ProvisionedResource struct {
+	// Outputs can be referenced from the workload as ${resources.<name>.<output>}.
+	Outputs map[string]interface{}
+	// SecretOutputs are the output keys that hold secrets, values derived from them never become plain service
+	// variables.
+	SecretOutputs []string
+}
+
+// Provisioner provisions the resources of the workloads. Provision returns nil if the provisioner does not handle the
+// resource, the next provisioner is then asked.
+type Provisioner interface {
+	Provision(ctx context.Context, res Resource) (*ProvisionedResource, error)
+}
+
+// ProvisionerFunc adapts a function to a Provisioner.
+type ProvisionerFunc func(ctx context.Context, res Resource) (*ProvisionedResource, error)
+
+// Provision implements Provisioner.
+func (f ProvisionerFunc) Provision(ctx context.Context, res Resource) (*ProvisionedResource, error) {
+	return f(ctx, res)
+}
+
+// Converter converts Score workloads into Avassa applications. The zero value is not usable, create one with
+// NewConverter.
+type Converter struct {
+	config             Config
+	defaultAnnotations map[string]string
+	provisioners       []Provisioner
+	validate           bool
+	strict             bool
+	diags              *Diagnostics
+	readFile           readFileFunc
+}
+
+// Option configures a Converter.
+type Option func(*Converter)
+
+// WithConfig sets the project wide security, network and label policy.
+func WithConfig(config Config) Option {
+	return func(c *Converter) {
+		c.config = config
+	}
+}
+
+// WithDefaultAnnotations sets annotations, such as avassa.log-size, on every workload that does not set them itself.
+func WithDefaultAnnotations(annotations map[string]string) Option {
+	return func(c *Converter) {
+		c.defaultAnnotations = maps.Clone(annotations)
+	}
+}
+
+// WithProvisioners adds provisioners for the workload resources, they are asked in order. Resources that no provisioner
+// handles get empty outputs.
+func WithProvisioners(provisioners ...Provisioner) Option {
+	return func(c *Converter) {
+		c.provisioners = append(c.provisioners, provisioners...)
+	}
+}
+
+// WithValidation enables or disables the Score schema validation of the workloads, it is enabled by default.
+func WithValidation(enabled bool) Option {
+	return func(c *Converter) {
+		c.validate = enabled
+	}
+}
+
+// WithStrict turns conversion warnings into an error.
+func WithStrict(strict bool) Option {
+	return func(c *Converter) {
+		c.strict = strict
+	}
+}
+
+// WithDiagnostics records the conversion warnings in diags.
+func WithDiagnostics(diags *Diagnostics) Option {
+	return func(c *Converter) {
+		c.diags = diags
+	}
+}
+
+// WithFileReader allows files[].source to be read with the given function, for example os.ReadFile. Without it,
+// workloads with file sources fail to convert.
+func WithFileReader(readFile func(path string) ([]byte, error)) Option {
+	return func(c *Converter) {
+		c.readFile = readFile
+	}
+}
+
+// NewConverter returns a Converter with the given options.
+func NewConverter(options ...Option) *Converter {
+	c := &Converter{
+		validate: true,
+		readFile: func(path string) ([]byte, error) {
+			return nil, fmt.Errorf("reading files is not enabled, see WithFileReader")
+		},
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+// DecodeWorkload turns a raw Score workload, for example decoded from yaml, into a Workload. It applies the Score
+// upgrade transforms, moves the x-avassa blocks aside and validates the rest against the Score schema. The raw workload
+// is modified.
+func (c *Converter) DecodeWorkload(rawWorkload map[string]interface{}) (Workload, error) {
+	if changes, err := scoreschema.ApplyCommonUpgradeTransforms(rawWorkload); err != nil {
+		return Workload{}, fmt.Errorf("failed to upgrade spec: %w", err)
+	} else if len(changes) > 0 {
+		for _, change := range changes {
+			slog.Info(fmt.Sprintf("Applying backwards compatible upgrade %s", change))
+		}
+	}
+	extensions, err := extractAvassaExtensions(rawWorkload)
+	if err != nil {
+		return Workload{}, err
+	}
+	var out Workload
+	if c.validate {
+		if err := scoreschema.Validate(rawWorkload); err != nil {
+			return Workload{}, err
+		}
+	}
+	if err := scoreloader.MapSpec(&out.Spec, rawWorkload); err != nil {
+		return Workload{}, fmt.Errorf("failed to decode workload: %w", err)
+	}
+	out.Extensions = extensions
+	return out, nil
+}
+
+// ConvertYAML converts a Score workload in yaml, including any x-avassa blocks.
+func (c *Converter) ConvertYAML(ctx context.Context, raw []byte) ([]Application, error) {
+	var rawWorkload map[string]interface{}
+	if err := yaml.Unmarshal(raw, &rawWorkload); err != nil {
+		return nil, fmt.Errorf("failed to decode score workload: %w", err)
+	}
+	workload, err := c.DecodeWorkload(rawWorkload)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConvertWorkloads(ctx, []Workload{workload})
+}
+
+// Convert converts Score workloads into one application each.
+func (c *Converter) Convert(ctx context.Context, workloads ...scoretypes.Workload) ([]Application, error) {
+	in := make([]Workload, len(workloads))
+	for i, w := range workloads {
+		in[i] = Workload{Spec: w}
+	}
+	return c.ConvertWorkloads(ctx, in)
+}
+
+// ConvertWorkloads converts the workloads into one application each, in the same order, skipping reference workloads.
+// The workloads are converted together so that they can refer to each other and share resources.
+func (c *Converter) ConvertWorkloads(ctx context.Context, workloads []Workload) ([]Application, error) {
+	currentState := &projectState{
+		Workloads:   map[string]framework.ScoreWorkloadState[Extensions]{},
+		Resources:   map[framework.ResourceUid]framework.ScoreResourceState[resourceExtras]{},
+		SharedState: map[string]interface{}{},
+	}
+	names := make([]string, 0, len(workloads))
+	references := make(map[string]bool)
+	for _, w := range workloads {
+		spec := w.Spec
+		spec.Metadata = withDefaultAnnotations(spec.Metadata, c.defaultAnnotations)
+		name, _ := spec.Metadata["name"].(string)
+		if c.validate {
+			if err := validateWorkload(spec); err != nil {
+				return nil, fmt.Errorf("workload: %s: %w", name, err)
+			}
+		}
+		if _, ok := currentState.Workloads[name]; ok {
+			return nil, fmt.Errorf("workload: %s: is given more than once", name)
+		}
+		var file *string
+		if w.File != "" {
+			file = &w.File
+		}
+		var err error
+		if currentState, err = currentState.WithWorkload(&spec, file, w.Extensions); err != nil {
+			return nil, fmt.Errorf("workload: %s: %w", name, err)
+		}
+		names = append(names, name)
+		references[name] = w.Reference
+	}
+
+	var err error
+	if currentState, err = currentState.WithPrimedResources(); err != nil {
+		return nil, fmt.Errorf("failed to prime resources: %w", err)
+	}
+	if currentState, err = provisionResources(ctx, currentState, c.provision); err != nil {
+		return nil, fmt.Errorf("failed to provision resources: %w", err)
+	}
+
+	diags := new(Diagnostics)
+	out := make([]Application, 0, len(names))
+	appNames := make(map[string]string, len(names))
+	for _, name := range names {
+		// reference workloads are still converted, as their application names take part in the collision check
+		workloadDiags := diags
+		if references[name] {
+			workloadDiags = nil
+		}
+		spec, manifest, err := convertWorkload(currentState, c.config, name, workloadDiags, c.readFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert workloads: %w", err)
+		}
+		app := Application{Workload: name, Name: spec.Name, Version: spec.Version, Spec: spec, Manifest: manifest}
+		appNames[name] = app.Name
+		if !references[name] {
+			out = append(out, app)
+		}
+	}
+	if err := checkNameCollisions(appNames); err != nil {
+		return nil, err
+	}
+
+	warnings := diags.Warnings()
+	for _, w := range warnings {
+		c.diags.Warn(w.Workload, w.Path, "%s", w.Message)
+	}
+	if c.strict && len(warnings) > 0 {
+		messages := make([]string, len(warnings))
+		for i, w := range warnings {
+			messages[i] = w.String()
+		}
+		return nil, fmt.Errorf("conversion produced %d warnings in strict mode: %s", len(warnings), strings.Join(messages, "; "))
+	}
+	return out, nil
+}
+
+// provision asks the configured provisioners for the outputs of the resource.
+func (c *Converter) provision(ctx context.Context, resUid framework.ResourceUid, resState *framework.ScoreResourceState[resourceExtras]) (bool, error) {
+	res := Resource{Uid: resUid, SourceWorkload: resState.SourceWorkload, Metadata: resState.Metadata, Params: resState.Params}
+	for _, p := range c.provisioners {
+		result, err := p.Provision(ctx, res)
+		if err != nil {
+			return false, err
+		} else if result != nil {
+			resState.Outputs = result.Outputs
+			resState.Extras.SecretOutputs = result.SecretOutputs
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// withDefaultAnnotations returns a copy of the metadata with the default annotations added where they are not set.
+func withDefaultAnnotations(metadata map[string]interface{}, defaults map[string]string) map[string]interface{} {
+	if len(defaults) == 0 {
+		return metadata
+	}
+	out := maps.Clone(metadata)
+	annotations := make(map[string]interface{}, len(defaults))
+	for k, v := range defaults {
+		annotations[k] = v
+	}
+	if existing, ok := metadata["annotations"].(map[string]interface{}); ok {
+		maps.Copy(annotations, existing)
+	}
+	out["annotations"] = annotations
+	return out
+}
+
+// validateWorkload validates a typed workload against the Score schema.
+func validateWorkload(spec scoretypes.Workload) error {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode workload: %w", err)
+	}
+	var rawWorkload map[string]interface{}
+	if err := json.Unmarshal(raw, &rawWorkload); err != nil {
+		return fmt.Errorf("failed to decode workload: %w", err)
+	}
+	return scoreschema.Validate(rawWorkload)
+}
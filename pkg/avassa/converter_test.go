@@ -0,0 +1,248 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	scoretypes "github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/score-spec/score-implementation-avassa/pkg/avassa"
+)
+
+func TestConvertYAML(t *testing.T) {
+	apps, err := avassa.NewConverter().ConvertYAML(context.Background(), []byte(`
+apiVersion: score.dev/v1b1
+metadata:
+  name: example
+  annotations:
+    avassa.io/version: "1.2"
+containers:
+  main:
+    image: nginx
+    variables:
+      GREETING: hello
+`))
+	require.NoError(t, err)
+	require.Len(t, apps, 1)
+	assert.Equal(t, "example", apps[0].Workload)
+	assert.Equal(t, "example", apps[0].Name)
+	assert.Equal(t, "1.2", apps[0].Version)
+	require.Len(t, apps[0].Spec.Services, 1)
+	assert.Equal(t, "example-service", apps[0].Spec.Services[0].Name)
+	require.Len(t, apps[0].Spec.Services[0].Containers, 1)
+	assert.Equal(t, "nginx", apps[0].Spec.Services[0].Containers[0].Image)
+	assert.Equal(t, map[string]string{"GREETING": "hello"}, apps[0].Spec.Services[0].Containers[0].Env)
+
+	raw, err := yaml.Marshal(apps[0])
+	require.NoError(t, err)
+	assert.Equal(t, `name: example
+version: "1.2"
+services:
+    - name: example-service
+      mode: replicated
+      replicas: 1
+      share-pid-namespace: false
+      containers:
+        - name: main
+          image: nginx
+          container-log-size: 100 MB
+          shutdown-timeout: 10s
+          mounts: []
+          env:
+            GREETING: hello
+on-mutable-variable-change: restart-service-instance
+`, string(raw))
+
+	raw, err = json.Marshal(apps[0])
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"example","version":"1.2","services":[{"name":"example-service","mode":"replicated","replicas":1,"share-pid-namespace":false,"containers":[{"name":"main","image":"nginx","container-log-size":"100 MB","shutdown-timeout":"10s","mounts":[],"env":{"GREETING":"hello"}}]}],"on-mutable-variable-change":"restart-service-instance"}`, string(raw))
+
+	_, err = avassa.NewConverter().ConvertYAML(context.Background(), []byte("apiVersion: score.dev/v1b1\nmetadata:\n  name: example\n"))
+	assert.ErrorContains(t, err, "jsonschema")
+}
+
+func TestConvertWithProvisioner(t *testing.T) {
+	workload := scoretypes.Workload{
+		ApiVersion: "score.dev/v1b1",
+		Metadata:   scoretypes.WorkloadMetadata{"name": "example"},
+		Containers: scoretypes.WorkloadContainers{
+			"main": {
+				Image:     "busybox",
+				Variables: scoretypes.ContainerVariables{"DB_HOST": "${resources.db.host}"},
+			},
+		},
+		Resources: scoretypes.WorkloadResources{"db": {Type: "postgres"}},
+	}
+
+	var requested []avassa.Resource
+	provisioner := avassa.ProvisionerFunc(func(ctx context.Context, res avassa.Resource) (*avassa.ProvisionedResource, error) {
+		requested = append(requested, res)
+		return &avassa.ProvisionedResource{Outputs: map[string]interface{}{"host": "db.internal"}}, nil
+	})
+	apps, err := avassa.NewConverter(avassa.WithProvisioners(provisioner)).Convert(context.Background(), workload)
+	require.NoError(t, err)
+	require.Len(t, requested, 1)
+	assert.Equal(t, "postgres.default#example.db", string(requested[0].Uid))
+	assert.Equal(t, "example", requested[0].SourceWorkload)
+	services := apps[0].Manifest["services"].([]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "DB_HOST", "value": "db.internal"}}, services[0].(map[string]interface{})["variables"])
+
+	// without a provisioner the resource has no outputs
+	_, err = avassa.NewConverter().Convert(context.Background(), workload)
+	assert.ErrorContains(t, err, "db.host")
+}
+
+func TestConvertHoistsResourceOutputs(t *testing.T) {
+	workload := scoretypes.Workload{
+		ApiVersion: "score.dev/v1b1",
+		Metadata:   scoretypes.WorkloadMetadata{"name": "example"},
+		Containers: scoretypes.WorkloadContainers{
+			"main": {
+				Image: "busybox",
+				Variables: scoretypes.ContainerVariables{
+					"DB_HOST":     "${resources.db.host}",
+					"DB_URL":      "postgres://${resources.db.host}:${resources.db.port}/app",
+					"DB_PASSWORD": "${resources.db.password}",
+				},
+			},
+		},
+		Resources: scoretypes.WorkloadResources{"db": {Type: "postgres"}},
+	}
+	provisioner := avassa.ProvisionerFunc(func(ctx context.Context, res avassa.Resource) (*avassa.ProvisionedResource, error) {
+		return &avassa.ProvisionedResource{
+			Outputs:       map[string]interface{}{"host": "db.local", "port": 5432, "password": "hunter2"},
+			SecretOutputs: []string{"password"},
+		}, nil
+	})
+	apps, err := avassa.NewConverter(avassa.WithProvisioners(provisioner)).Convert(context.Background(), workload)
+	require.NoError(t, err)
+
+	// secret outputs stay inline rather than becoming a service variable
+	service := apps[0].Manifest["services"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "DB_HOST", "value": "db.local"},
+		map[string]interface{}{"name": "DB_URL", "value": "postgres://db.local:5432/app"},
+	}, service["variables"])
+	container := service["containers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"DB_HOST":     "${DB_HOST}",
+		"DB_URL":      "${DB_URL}",
+		"DB_PASSWORD": "hunter2",
+	}, container["env"])
+}
+
+func TestConvertDiagnostics(t *testing.T) {
+	workload := scoretypes.Workload{
+		ApiVersion: "score.dev/v1b1",
+		Metadata:   scoretypes.WorkloadMetadata{"name": "example"},
+		Containers: scoretypes.WorkloadContainers{
+			"main": {
+				Image:     "busybox",
+				Files:     scoretypes.ContainerFiles{"/etc/app.conf": {Source: ptr("app.conf")}},
+				Resources: &scoretypes.ContainerResources{Limits: &scoretypes.ResourcesLimits{Cpu: ptr("1")}},
+			},
+		},
+	}
+
+	_, err := avassa.NewConverter().Convert(context.Background(), workload)
+	assert.ErrorContains(t, err, "reading files is not enabled, see WithFileReader")
+
+	readFile := func(path string) ([]byte, error) {
+		assert.Equal(t, "app.conf", path)
+		return []byte("key = value\n"), nil
+	}
+	diags := new(avassa.Diagnostics)
+	apps, err := avassa.NewConverter(avassa.WithFileReader(readFile), avassa.WithDiagnostics(diags)).Convert(context.Background(), workload)
+	require.NoError(t, err)
+	require.Len(t, diags.Warnings(), 1)
+	assert.Equal(t, "example", diags.Warnings()[0].Workload)
+	assert.Equal(t, "containers.main.resources.limits", diags.Warnings()[0].Path)
+
+	// files are mounted from a config map volume
+	service := apps[0].Manifest["services"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name": "main-files",
+		"config-map": map[string]interface{}{"items": []interface{}{
+			map[string]interface{}{"name": "app.conf", "data": "key = value\n"},
+		}},
+	}}, service["volumes"])
+	container := service["containers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"volume-name": "main-files",
+		"files":       []interface{}{map[string]interface{}{"name": "app.conf", "mount-path": "/etc/app.conf"}},
+	}}, container["mounts"])
+
+	_, err = avassa.NewConverter(avassa.WithFileReader(readFile), avassa.WithStrict(true)).Convert(context.Background(), workload)
+	assert.ErrorContains(t, err, "in strict mode")
+}
+
+func TestConvertReferenceWorkloads(t *testing.T) {
+	web := avassa.Workload{Spec: scoretypes.Workload{
+		ApiVersion: "score.dev/v1b1",
+		Metadata: scoretypes.WorkloadMetadata{
+			"name":        "web",
+			"annotations": map[string]interface{}{"avassa.placement.preferred-affinity": "db"},
+		},
+		Containers: scoretypes.WorkloadContainers{"main": {Image: "nginx"}},
+	}}
+	db := avassa.Workload{Spec: scoretypes.Workload{
+		ApiVersion: "score.dev/v1b1",
+		Metadata: scoretypes.WorkloadMetadata{
+			"name":        "db",
+			"annotations": map[string]interface{}{"avassa.replica": "2"},
+		},
+		Containers: scoretypes.WorkloadContainers{"main": {Image: "postgres"}},
+	}, Reference: true}
+
+	diags := new(avassa.Diagnostics)
+	apps, err := avassa.NewConverter(avassa.WithStrict(true), avassa.WithDiagnostics(diags)).ConvertWorkloads(context.Background(), []avassa.Workload{web, db})
+	require.NoError(t, err)
+	require.Len(t, apps, 1)
+	assert.Equal(t, "web", apps[0].Workload)
+	assert.Empty(t, diags.Warnings())
+	service := apps[0].Manifest["services"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"preferred-affinity": map[string]interface{}{"services": []interface{}{"db.db-service"}}}, service["placement"])
+}
+
+func TestConvertDefaultAnnotations(t *testing.T) {
+	workload := scoretypes.Workload{
+		ApiVersion: "score.dev/v1b1",
+		Metadata: scoretypes.WorkloadMetadata{
+			"name":        "example",
+			"annotations": map[string]interface{}{"avassa.io/version": "2.0"},
+		},
+		Containers: scoretypes.WorkloadContainers{"main": {Image: "busybox"}},
+	}
+	converter := avassa.NewConverter(avassa.WithDefaultAnnotations(map[string]string{"avassa.io/version": "1.0", "avassa.io/mode": "one-per-matching-host"}))
+	apps, err := converter.Convert(context.Background(), workload)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0", apps[0].Version)
+	services := apps[0].Manifest["services"].([]interface{})
+	assert.Equal(t, "one-per-matching-host", services[0].(map[string]interface{})["mode"])
+	assert.Equal(t, map[string]interface{}{"avassa.io/version": "2.0"}, workload.Metadata["annotations"])
+
+	_, err = converter.Convert(context.Background(), workload, workload)
+	assert.EqualError(t, err, "workload: example: is given more than once")
+}
+
+func ptr[k any](v k) *k {
+	return &v
+}
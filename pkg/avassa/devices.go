@@ -0,0 +1,105 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Devices lists the devices of a container.
+type Devices struct {
+	DeviceLabels []string `yaml:"device-labels,omitempty"`
+}
+
+// GPU requests GPUs for a container.
+type GPU struct {
+	Labels      []string `yaml:"labels,omitempty"`
+	NumberGPUs  *int     `yaml:"number-gpus,omitempty"`
+	GPUPatterns []string `yaml:"gpu-patterns,omitempty"`
+}
+
+// applyContainerDevices sets the device and gpu passthrough of the container from the (per-container) annotations:
+//
+//	avassa.devices.device-labels   comma-separated device label names
+//	avassa.gpu.labels              comma-separated gpu label names
+//	avassa.gpu.number-gpus         number of gpus to mount
+//	avassa.gpu.gpu-patterns        ';'-separated gpu patterns, since a single pattern may contain ','
+func applyContainerDevices(ac *Container, containerName string, annotations map[string]interface{}) error {
+	if v, ok := containerAnnotation(annotations, containerName, "devices.device-labels"); ok {
+		if labels := splitList(asString(v)); len(labels) > 0 {
+			ac.Devices = &Devices{DeviceLabels: labels}
+		}
+	}
+	var gpu GPU
+	if v, ok := containerAnnotation(annotations, containerName, "gpu.labels"); ok {
+		gpu.Labels = splitList(asString(v))
+	}
+	if v, ok := containerAnnotation(annotations, containerName, "gpu.number-gpus"); ok {
+		n, err := parseUint(v, 1, math.MaxUint8)
+		if err != nil {
+			return fmt.Errorf("gpu: number-gpus: %w", err)
+		}
+		gpu.NumberGPUs = &n
+	}
+	if v, ok := containerAnnotation(annotations, containerName, "gpu.gpu-patterns"); ok {
+		for _, p := range strings.Split(asString(v), ";") {
+			if p = strings.TrimSpace(p); p != "" {
+				gpu.GPUPatterns = append(gpu.GPUPatterns, p)
+			}
+		}
+	}
+	if len(gpu.Labels) > 0 || gpu.NumberGPUs != nil || len(gpu.GPUPatterns) > 0 {
+		ac.GPU = &gpu
+	}
+	return nil
+}
+
+// validateContainerDevices checks the device and gpu settings of every container against the schema formats, after
+// x-avassa has been merged.
+func validateContainerDevices(app *ApplicationSpec) error {
+	for _, svc := range app.Services {
+		for _, c := range svc.Containers {
+			if c.Devices != nil {
+				for _, l := range c.Devices.DeviceLabels {
+					if err := validateLabelName(l); err != nil {
+						return fmt.Errorf("container: %s: devices: device-labels: %w", c.Name, err)
+					}
+				}
+			}
+			if c.GPU == nil {
+				continue
+			}
+			if len(c.GPU.Labels) == 0 {
+				return fmt.Errorf("container: %s: gpu: labels must be set to select gpus", c.Name)
+			}
+			for _, l := range c.GPU.Labels {
+				if err := validateLabelName(l); err != nil {
+					return fmt.Errorf("container: %s: gpu: labels: %w", c.Name, err)
+				}
+			}
+			if c.GPU.NumberGPUs != nil && (*c.GPU.NumberGPUs < 1 || *c.GPU.NumberGPUs > math.MaxUint8) {
+				return fmt.Errorf("container: %s: gpu: number-gpus: '%d' is out of range, expected 1 to %d", c.Name, *c.GPU.NumberGPUs, math.MaxUint8)
+			}
+			for _, p := range c.GPU.GPUPatterns {
+				if err := validateGPUPattern(p); err != nil {
+					return fmt.Errorf("container: %s: gpu: gpu-patterns: %w", c.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	scoretypes "github.com/score-spec/score-go/types"
+)
+
+// Diagnostic is a single conversion warning about part of a Score workload that was dropped or changed on the way to
+// the Avassa application.
+type Diagnostic struct {
+	Workload string
+	Path     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("workload: %s: %s: %s", d.Workload, d.Path, d.Message)
+}
+
+// Diagnostics collects the warnings raised while converting workloads. A nil collector discards them.
+type Diagnostics struct {
+	items []Diagnostic
+}
+
+// Warn records a warning for the given path within the workload.
+func (d *Diagnostics) Warn(workloadName, path, format string, args ...interface{}) {
+	if d == nil {
+		return
+	}
+	d.items = append(d.items, Diagnostic{Workload: workloadName, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Warnings returns the recorded warnings ordered by workload and path.
+func (d *Diagnostics) Warnings() []Diagnostic {
+	if d == nil {
+		return nil
+	}
+	out := slices.Clone(d.items)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Workload != out[j].Workload {
+			return out[i].Workload < out[j].Workload
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// knownWorkloadAnnotations are the avassa.* annotations that only apply to the whole workload.
+var knownWorkloadAnnotations = []string{
+	"approle",
+	"delayed-shutdown.max-number-of-instances",
+	"delayed-shutdown.timeout",
+	"io/mode",
+	"io/version",
+	"log-archive",
+	"log-size",
+	"network",
+	"on-mounted-file-change-restart",
+	"on-mutable-variable-change",
+	"outbound-access.default-action",
+	"outbound-access.rules",
+	"placement.match-host-labels",
+	"placement.preferred-affinity",
+	"placement.preferred-anti-affinity",
+	"replicas",
+	"resources.network.downstream-bandwidth-per-host",
+	"resources.network.upstream-bandwidth-per-host",
+	"share-pid-namespace",
+	"shutdown-timeout",
+	"upgrade-from.healthy-time",
+	"upgrade-from.instances-in-parallel",
+	"upgrade-from.method",
+	"upgrade-from.version-regexp",
+}
+
+// knownContainerAnnotations are the avassa.* annotations that may also be set for a single container with
+// avassa.containers.<container>.<key>.
+var knownContainerAnnotations = func() []string {
+	out := []string{
+		"additional-capabilities",
+		"delayed-shutdown-cmd",
+		"devices.device-labels",
+		"gpu.gpu-patterns",
+		"gpu.labels",
+		"gpu.number-gpus",
+		"probes.startup.from",
+		"security.apparmor.disabled",
+		"security.selinux.disabled",
+		"user",
+		"user-namespace.host",
+	}
+	for _, kind := range []string{probeLiveness, probeReadiness, probeStartup} {
+		for _, key := range []string{"tcp-port", "initial-delay", "period", "timeout", "success-threshold", "failure-threshold"} {
+			out = append(out, "probes."+kind+"."+key)
+		}
+	}
+	return out
+}()
+
+// booleanAnnotations are read with a default, so any value other than true or false is silently ignored.
+var booleanAnnotations = []string{
+	"log-archive",
+	"on-mounted-file-change-restart",
+	"share-pid-namespace",
+	"security.apparmor.disabled",
+	"security.selinux.disabled",
+	"user-namespace.host",
+}
+
+// collectDiagnostics warns about the parts of the Score workload that have no Avassa equivalent and about avassa.*
+// annotations that the converter does not understand.
+func collectDiagnostics(spec scoretypes.Workload, workloadName string, diags *Diagnostics) {
+	if diags == nil {
+		return
+	}
+	if spec.Service != nil && len(spec.Service.Ports) > 0 {
+		diags.Warn(workloadName, "service.ports", "service ports are not supported and were dropped, expose them through x-avassa network settings instead")
+	}
+	for containerName, c := range spec.Containers {
+		prefix := "containers." + containerName
+		if c.Resources != nil && c.Resources.Requests != nil {
+			diags.Warn(workloadName, prefix+".resources.requests", "resource requests are not supported and were dropped")
+		}
+		if c.Resources != nil && c.Resources.Limits != nil {
+			diags.Warn(workloadName, prefix+".resources.limits", "resource limits are not supported and were dropped")
+		}
+		if len(c.Volumes) > 0 {
+			diags.Warn(workloadName, prefix+".volumes", "volumes are not supported and were dropped")
+		}
+		for _, probe := range []struct {
+			name string
+			spec *scoretypes.ContainerProbe
+		}{{"livenessProbe", c.LivenessProbe}, {"readinessProbe", c.ReadinessProbe}} {
+			if probe.spec == nil || probe.spec.HttpGet == nil {
+				continue
+			}
+			seen := make(map[string]bool)
+			for _, h := range probe.spec.HttpGet.HttpHeaders {
+				name := strings.TrimSpace(h.Name)
+				if seen[name] {
+					diags.Warn(workloadName, prefix+"."+probe.name+".httpGet.httpHeaders", "multiple values for header '%s' were joined into one", name)
+				}
+				seen[name] = true
+			}
+		}
+	}
+
+	for key, value := range workloadAnnotations(spec.Metadata) {
+		rest, ok := strings.CutPrefix(key, "avassa.")
+		if !ok || strings.HasPrefix(key, variableAnnotationPrefix) {
+			continue
+		}
+		path := "metadata.annotations." + key
+		if containerKey, ok := strings.CutPrefix(rest, "containers."); ok {
+			containerName, subKey, _ := strings.Cut(containerKey, ".")
+			if _, ok := spec.Containers[containerName]; !ok {
+				diags.Warn(workloadName, path, "unknown container '%s', the annotation was ignored", containerName)
+				continue
+			}
+			rest = subKey
+			if !slices.Contains(knownContainerAnnotations, rest) {
+				diags.Warn(workloadName, path, "unknown per-container annotation, the annotation was ignored")
+				continue
+			}
+		} else if !slices.Contains(knownWorkloadAnnotations, rest) && !slices.Contains(knownContainerAnnotations, rest) {
+			diags.Warn(workloadName, path, "unknown annotation, the annotation was ignored")
+			continue
+		}
+		if slices.Contains(booleanAnnotations, rest) {
+			if v := asString(value); v != "true" && v != "false" {
+				diags.Warn(workloadName, path, "'%s' is not true or false, the default was used", v)
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestJSON renders the manifest as compact json with the same key order and number handling as the yaml output.
+func manifestJSON(manifest map[string]interface{}) ([]byte, error) {
+	out := new(bytes.Buffer)
+	if err := writeJSONNode(out, manifestNode(manifest, "")); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// manifestNode converts the manifest into a yaml node tree with a deterministic key order, see orderedKeys. The path is
+// the position of the value within the application, such as services[].containers[].
+func manifestNode(v interface{}, path string) *yaml.Node {
+	switch t := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}
+	case bool:
+		if t {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", t)}
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", t)}
+	case float64:
+		// Keep integers clean even if represented as float64
+		if float64(int64(t)) == t {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprintf("%d", int64(t))}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strings.TrimRight(strings.TrimRight(fmt.Sprintf("%f", t), "0"), ".")}
+	case []interface{}:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, el := range t {
+			seq.Content = append(seq.Content, manifestNode(el, path+"[]"))
+		}
+		return seq
+	case map[string]interface{}:
+		m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range orderedKeys(t, path) {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k})
+			m.Content = append(m.Content, manifestNode(t[k], childPath))
+		}
+		return m
+	default:
+		// Fallback: encode via yaml then decode to node (rare)
+		var n yaml.Node
+		if raw, err := yaml.Marshal(t); err == nil {
+			_ = yaml.Unmarshal(raw, &n)
+			return &n
+		}
+		// As a last resort, string-format
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprintf("%v", t)}
+	}
+}
+
+// manifestKeyOrder is the key order of the main objects of an application. It is maintained by hand, following the
+// Avassa application reference and its examples (see visitor-counter.app.yaml), as appspec-schema.json only lists
+// keys alphabetically. TestManifestKeyOrderMatchesSchema checks the keys against the schema. Keys that are not listed
+// follow alphabetically.
+var manifestKeyOrder = map[string][]string{
+	"":                       {"name", "version", "services", "on-mutable-variable-change", "labels", "network", "resources", "upgrade-from"},
+	"services[]":             {"name", "mode", "replicas", "share-pid-namespace", "variables", "volumes", "containers", "network", "placement", "delayed-shutdown"},
+	"services[].variables[]": {"name", "value", "value-from-vault-secret"},
+	"services[].variables[].value-from-vault-secret": {"vault", "secret", "key", "from-tenant"},
+	"services[].containers[]":                        {"name", "image", "cmd", "container-log-size", "container-log-archive", "shutdown-timeout", "mounts", "env", "approle", "on-mounted-file-change", "probes"},
+	"services[].containers[].probes":                 {"readiness", "liveness", "startup"},
+	"services[].containers[].mounts[]":               {"volume-name", "files"},
+	"services[].delayed-shutdown":                    {"timeout", "max-number-of-instances"},
+	"upgrade-from[]":                                 {"method", "version-regexp", "services"},
+}
+
+// probeKeyOrder is the key order of each probe, which may be nested under any of the probe kinds.
+var probeKeyOrder = []string{"exec", "http", "tcp", "initial-delay", "timeout", "period", "success-threshold", "failure-threshold"}
+
+// httpProbeKeyOrder is the key order of http probes.
+var httpProbeKeyOrder = []string{"scheme", "host", "port", "path", "request-headers"}
+
+// orderedKeys returns the keys of the map at the given path: the known keys of the path in manifestKeyOrder first,
+// then name for every item of a list, then the remaining keys alphabetically.
+func orderedKeys(m map[string]interface{}, path string) []string {
+	order, ok := manifestKeyOrder[path]
+	if !ok {
+		if probe, ok := strings.CutPrefix(path, "services[].containers[].probes."); ok {
+			if strings.HasSuffix(probe, ".http") {
+				order = httpProbeKeyOrder
+			} else if !strings.Contains(probe, ".") {
+				order = probeKeyOrder
+			}
+		}
+	}
+	if len(order) == 0 && strings.HasSuffix(path, "[]") {
+		order = []string{"name"}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		ri, rj := slices.Index(order, keys[i]), slices.Index(order, keys[j])
+		switch {
+		case ri >= 0 && rj >= 0:
+			return ri < rj
+		case ri >= 0 || rj >= 0:
+			return ri >= 0
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+	return keys
+}
+
+// writeJSONNode renders a yaml node tree built by manifestNode as compact json, keeping the order of mapping keys.
+func writeJSONNode(out *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			out.WriteString("null")
+			return nil
+		}
+		return writeJSONNode(out, n.Content[0])
+	case yaml.MappingNode:
+		out.WriteString("{")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			key, _ := json.Marshal(n.Content[i].Value)
+			out.Write(key)
+			out.WriteString(":")
+			if err := writeJSONNode(out, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+	case yaml.SequenceNode:
+		out.WriteString("[")
+		for i, item := range n.Content {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := writeJSONNode(out, item); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!null":
+			out.WriteString("null")
+		case "!!bool", "!!int", "!!float":
+			out.WriteString(n.Value)
+		default:
+			raw, _ := json.Marshal(n.Value)
+			out.Write(raw)
+		}
+	default:
+		return fmt.Errorf("unsupported yaml node kind %d", n.Kind)
+	}
+	return nil
+}
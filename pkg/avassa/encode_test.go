@@ -0,0 +1,107 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaNodes returns the schema objects at the manifest path, such as services[].containers[], following
+// properties, array items and the oneOf alternatives of the Avassa application spec schema.
+func schemaNodes(node map[string]interface{}, path string) []map[string]interface{} {
+	nodes := []map[string]interface{}{node}
+	if path == "" {
+		return nodes
+	}
+	for _, part := range strings.Split(path, ".") {
+		name, isList := strings.CutSuffix(part, "[]")
+		var next []map[string]interface{}
+		for _, n := range alternatives(nodes) {
+			if properties, ok := n["properties"].(map[string]interface{}); ok {
+				if child, ok := properties[name].(map[string]interface{}); ok {
+					next = append(next, child)
+				}
+			}
+		}
+		if isList {
+			var items []map[string]interface{}
+			for _, n := range alternatives(next) {
+				if item, ok := n["items"].(map[string]interface{}); ok {
+					items = append(items, item)
+				}
+			}
+			next = items
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// alternatives returns the schema objects together with their oneOf alternatives.
+func alternatives(nodes []map[string]interface{}) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, n := range nodes {
+		out = append(out, n)
+		oneOf, _ := n["oneOf"].([]interface{})
+		for _, alternative := range oneOf {
+			if m, ok := alternative.(map[string]interface{}); ok {
+				out = append(out, alternatives([]map[string]interface{}{m})...)
+			}
+		}
+	}
+	return out
+}
+
+// schemaHasKey reports whether the schema allows the key in the objects at the path.
+func schemaHasKey(schema map[string]interface{}, path, key string) bool {
+	for _, n := range alternatives(schemaNodes(schema, path)) {
+		if properties, ok := n["properties"].(map[string]interface{}); ok {
+			if _, ok := properties[key]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TestManifestKeyOrderMatchesSchema checks the hand-maintained key order tables against appspec-schema.json, so that
+// a renamed or removed key in the schema does not silently fall back to alphabetical order.
+func TestManifestKeyOrderMatchesSchema(t *testing.T) {
+	raw, err := os.ReadFile("../../appspec-schema.json")
+	require.NoError(t, err)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &schema))
+
+	orders := make(map[string][]string, len(manifestKeyOrder))
+	for path, keys := range manifestKeyOrder {
+		orders[path] = keys
+	}
+	for _, kind := range manifestKeyOrder["services[].containers[].probes"] {
+		orders["services[].containers[].probes."+kind] = probeKeyOrder
+		orders["services[].containers[].probes."+kind+".http"] = httpProbeKeyOrder
+	}
+	for path, keys := range orders {
+		require.NotEmpty(t, schemaNodes(schema, path), "path %s is not in the schema", path)
+		for _, key := range keys {
+			assert.True(t, schemaHasKey(schema, path, key), "key %s of %s is not in the schema", key, path)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"bytes"
+	"fmt"
+
+	"dario.cat/mergo"
+	"gopkg.in/yaml.v3"
+)
+
+// avassaExtensionKey is the Score extension block holding raw Avassa fields. It is allowed at the top level of the
+// workload and on each container.
+const avassaExtensionKey = "x-avassa"
+
+// avassaServiceExtensionKey is the key within the workload extension block that targets the generated service rather
+// than the application.
+const avassaServiceExtensionKey = "service"
+
+// extractAvassaExtensions removes the x-avassa extension blocks from the raw Score workload so that it passes Score
+// validation, and returns them as workload extras for buildAvassaApplication to merge in.
+func extractAvassaExtensions(rawWorkload map[string]interface{}) (Extensions, error) {
+	var out Extensions
+	if raw, ok := rawWorkload[avassaExtensionKey]; ok {
+		ext, ok := raw.(map[string]interface{})
+		if !ok {
+			return out, fmt.Errorf("%s: expected a map", avassaExtensionKey)
+		}
+		delete(rawWorkload, avassaExtensionKey)
+		if len(ext) > 0 {
+			out.Avassa = ext
+		}
+	}
+	rawContainers, _ := rawWorkload["containers"].(map[string]interface{})
+	for containerName, rawContainer := range rawContainers {
+		container, _ := rawContainer.(map[string]interface{})
+		raw, ok := container[avassaExtensionKey]
+		if !ok {
+			continue
+		}
+		ext, ok := raw.(map[string]interface{})
+		if !ok {
+			return out, fmt.Errorf("containers: %s: %s: expected a map", containerName, avassaExtensionKey)
+		}
+		delete(container, avassaExtensionKey)
+		if len(ext) > 0 {
+			if out.ContainerAvassa == nil {
+				out.ContainerAvassa = make(map[string]map[string]interface{})
+			}
+			out.ContainerAvassa[containerName] = ext
+		}
+	}
+	return out, nil
+}
+
+// mergeAvassaExtension deep merges the extension into the target by round-tripping it through a generic map. Values in
+// the extension take precedence, lists are replaced rather than appended, and unknown fields are kept in the Extra map
+// of the target.
+func mergeAvassaExtension[T any](target *T, ext map[string]interface{}) error {
+	raw, err := yaml.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("failed to serialise: %w", err)
+	}
+	var current map[string]interface{}
+	if err := yaml.Unmarshal(raw, &current); err != nil {
+		return fmt.Errorf("failed to deserialise: %w", err)
+	}
+	if err := mergo.Merge(&current, ext, mergo.WithOverride); err != nil {
+		return fmt.Errorf("failed to merge: %w", err)
+	}
+	if raw, err = yaml.Marshal(current); err != nil {
+		return fmt.Errorf("failed to serialise merged result: %w", err)
+	}
+	// Unknown fields of nested types can't be represented, so fail rather than silently dropping them
+	var out T
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&out); err != nil {
+		return fmt.Errorf("failed to decode merged result: %w", err)
+	}
+	*target = out
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+
+	scoretypes "github.com/score-spec/score-go/types"
+)
+
+// filesVolumeSuffix is appended to the container name to name the config map volume that holds its files.
+const filesVolumeSuffix = "-files"
+
+var fileModeRe = regexp.MustCompile(`^0?([0-7]{3})$`)
+
+// filesVolumeName returns the name of the config map volume generated for the files of the container.
+func filesVolumeName(containerName string) string {
+	return truncateName(sanitizeName(containerName) + filesVolumeSuffix)
+}
+
+// buildFileMount turns the resolved files of a container into a config map volume with one item per file and a
+// mount of each item at its target path. Items are named after the base name of the target, with a counter added when
+// two targets share a base name. Avassa expands variables in the item data, so files with noExpand set are written as
+// data-verbatim, while the expanded content of other files is passed on like container variables. It returns nil
+// values when the container has no files.
+func buildFileMount(containerName string, files map[string]scoretypes.ContainerFile) (map[string]interface{}, map[string]interface{}, error) {
+	if len(files) == 0 {
+		return nil, nil, nil
+	}
+	volumeName := filesVolumeName(containerName)
+	items := make([]interface{}, 0, len(files))
+	mounts := make([]interface{}, 0, len(files))
+	used := make(map[string]bool, len(files))
+	for _, target := range slices.Sorted(maps.Keys(files)) {
+		file := files[target]
+		name := path.Base(target)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", path.Base(target), i)
+		}
+		used[name] = true
+		item := map[string]interface{}{"name": name, "data": *file.Content}
+		if file.NoExpand != nil && *file.NoExpand {
+			item = map[string]interface{}{"name": name, "data-verbatim": *file.Content}
+		}
+		if file.Mode != nil {
+			m := fileModeRe.FindStringSubmatch(*file.Mode)
+			if m == nil {
+				return nil, nil, fmt.Errorf("files: %s: mode: '%s' is not an octal file mode such as 0644", target, *file.Mode)
+			}
+			item["file-mode"] = m[1]
+		}
+		items = append(items, item)
+		mounts = append(mounts, map[string]interface{}{"name": name, "mount-path": target})
+	}
+	volume := map[string]interface{}{"name": volumeName, "config-map": map[string]interface{}{"items": items}}
+	mount := map[string]interface{}{"volume-name": volumeName, "files": mounts}
+	return volume, mount, nil
+}
+
+// checkVolumeNames fails when the service has two volumes with the same name, for example when a volume added through
+// x-avassa reuses the name of a generated files volume.
+func checkVolumeNames(svc Service) error {
+	seen := make(map[string]bool, len(svc.Volumes))
+	for _, raw := range svc.Volumes {
+		v, _ := raw.(map[string]interface{})
+		name := asString(v["name"])
+		if seen[name] {
+			return fmt.Errorf("volumes: duplicate volume name '%s'", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
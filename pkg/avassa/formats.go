@@ -0,0 +1,152 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Validators for the string formats used by the Avassa application spec schema (appspec-schema.json).
+
+var durationRe = regexp.MustCompile(`^([0-9]+y)?([0-9]+d)?([0-9]+h)?([0-9]+m)?([0-9]+s)?$`)
+
+// validateDuration checks the Avassa duration format [<digits>y][<digits>d][<digits>h][<digits>m][<digits>s].
+func validateDuration(v string) error {
+	if v == "" || !durationRe.MatchString(v) {
+		return fmt.Errorf("'%s' is not a valid duration, expected a value like 30s, 5m or 1h30m", v)
+	}
+	return nil
+}
+
+// parseUint parses an integer annotation value within [min, max]. Annotations are strings in Score, but values coming
+// from x-avassa or overrides may already be numbers.
+func parseUint(v interface{}, min, max uint64) (int, error) {
+	var out uint64
+	switch t := v.(type) {
+	case int:
+		if t < 0 {
+			return 0, fmt.Errorf("'%d' must not be negative", t)
+		}
+		out = uint64(t)
+	case float64:
+		if t < 0 || t != math.Trunc(t) {
+			return 0, fmt.Errorf("'%v' is not a valid unsigned integer", t)
+		}
+		out = uint64(t)
+	default:
+		var err error
+		if out, err = strconv.ParseUint(strings.TrimSpace(asString(v)), 10, 64); err != nil {
+			return 0, fmt.Errorf("'%v' is not a valid unsigned integer", v)
+		}
+	}
+	if out < min || out > max {
+		return 0, fmt.Errorf("'%d' is out of range, expected %d to %d", out, min, max)
+	}
+	return int(out), nil
+}
+
+var (
+	domainNameRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9\-]*[a-zA-Z0-9])?)*$`)
+	labelSegRe   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._\-]*[a-zA-Z0-9])?$`)
+)
+
+// validateLabelName checks the label-name format: an optional DNS domain prefix followed by '/' and a name segment,
+// for example example.com/color or color.
+func validateLabelName(v string) error {
+	prefix, name, hasPrefix := strings.Cut(v, "/")
+	if !hasPrefix {
+		prefix, name = "", v
+	}
+	if hasPrefix && (len(prefix) > 253 || !domainNameRe.MatchString(prefix)) {
+		return fmt.Errorf("'%s' is not a valid label name, the prefix '%s' must be a DNS domain name", v, prefix)
+	}
+	if len(name) > 63 || !labelSegRe.MatchString(name) {
+		return fmt.Errorf("'%s' is not a valid label name, the name must be alphanumeric with '-', '_' or '.' inside", v)
+	}
+	return nil
+}
+
+var (
+	gpuPatternRe = regexp.MustCompile(`^\s*([a-z\-]+)\s*(==|!=|<=|>=|<|>)\s*"([^"]*)"\s*$`)
+	gpuKeys      = []string{"name", "vendor", "serial", "memory", "driver-version", "compute-mode", "compute-capability", "display-mode", "id"}
+	gpuOrderKeys = []string{"memory", "driver-version", "compute-capability"}
+)
+
+// validateGPUPattern checks the gpu-pattern format: comma-separated key-op-"value" expressions where the ordering
+// operators are only allowed on memory, driver-version and compute-capability.
+func validateGPUPattern(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return fmt.Errorf("gpu pattern must not be empty")
+	}
+	for _, expr := range splitOutsideQuotes(v, ',') {
+		m := gpuPatternRe.FindStringSubmatch(expr)
+		if m == nil {
+			return fmt.Errorf("'%s' is not a valid gpu pattern, expected key==\"value\" expressions separated by ','", v)
+		}
+		if !slices.Contains(gpuKeys, m[1]) {
+			return fmt.Errorf("'%s' is not a valid gpu pattern, unknown key '%s'", v, m[1])
+		}
+		if m[2] != "==" && m[2] != "!=" && !slices.Contains(gpuOrderKeys, m[1]) {
+			return fmt.Errorf("'%s' is not a valid gpu pattern, '%s' can only be used with %s", v, m[2], strings.Join(gpuOrderKeys, ", "))
+		}
+	}
+	return nil
+}
+
+// splitOutsideQuotes splits the input on the separator, ignoring separators within double quotes.
+func splitOutsideQuotes(in string, sep rune) []string {
+	var out []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range in {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == sep && !quoted:
+			out = append(out, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(out, current.String())
+}
+
+var bandwidthRe = regexp.MustCompile(`^[0-9]+ ?(bit/s|kbit/s|Mbit/s|Gbit/s|Tbit/s|Kibit/s|Mibit/s|Gibit/s|Tibit/s)$`)
+
+// validateBandwidth checks the bandwidth format: unlimited, or bits per second with an SI (kbit/s, Mbit/s, Gbit/s) or
+// ISO/IEC (Kibit/s, Mibit/s, Gibit/s) prefix, for example 12 Gibit/s.
+func validateBandwidth(v string) error {
+	if v != "unlimited" && !bandwidthRe.MatchString(v) {
+		return fmt.Errorf("'%s' is not a valid bandwidth, expected a value like 10 Mbit/s, 512 Kibit/s or unlimited", v)
+	}
+	return nil
+}
+
+var labelValueRe = regexp.MustCompile(`^[^\s()]*$`)
+
+// validateLabelValue checks the label-value format: a string without whitespace or parentheses.
+func validateLabelValue(v string) error {
+	if !labelValueRe.MatchString(v) {
+		return fmt.Errorf("'%s' is not a valid label value, it must not contain whitespace or parentheses", v)
+	}
+	return nil
+}
@@ -0,0 +1,523 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	scoretypes "github.com/score-spec/score-go/types"
+	"gopkg.in/yaml.v3"
+)
+
+// ScoreWorkload is a Score workload produced by an import. Fields without a Score equivalent are kept in the x-avassa
+// extension blocks so that generate reproduces them. It marshals to a Score file.
+type ScoreWorkload struct {
+	APIVersion string                         `yaml:"apiVersion"`
+	Metadata   map[string]interface{}         `yaml:"metadata"`
+	Containers map[string]ScoreContainer      `yaml:"containers"`
+	Resources  map[string]scoretypes.Resource `yaml:"resources,omitempty"`
+	Avassa     map[string]interface{}         `yaml:"x-avassa,omitempty"`
+}
+
+// ScoreContainer is a container of an imported Score workload.
+type ScoreContainer struct {
+	Image          string                                `yaml:"image"`
+	Command        []string                              `yaml:"command,omitempty"`
+	Args           []string                              `yaml:"args,omitempty"`
+	Variables      map[string]string                     `yaml:"variables,omitempty"`
+	Files          map[string]scoretypes.ContainerFile   `yaml:"files,omitempty"`
+	Volumes        map[string]scoretypes.ContainerVolume `yaml:"volumes,omitempty"`
+	LivenessProbe  *scoretypes.ContainerProbe            `yaml:"livenessProbe,omitempty"`
+	ReadinessProbe *scoretypes.ContainerProbe            `yaml:"readinessProbe,omitempty"`
+	Avassa         map[string]interface{}                `yaml:"x-avassa,omitempty"`
+}
+
+// Name returns the workload name from the metadata.
+func (w ScoreWorkload) Name() string {
+	return asString(w.Metadata["name"])
+}
+
+// scoreAPIVersion is the Score specification version of imported workloads.
+const scoreAPIVersion = "score.dev/v1b1"
+
+const (
+	defaultOnMutableVariableChange = "restart-service-instance"
+	defaultContainerLogSize        = "100 MB"
+	defaultShutdownTimeout         = "10s"
+)
+
+var probeTimingKeys = []string{"initial-delay", "timeout", "period", "success-threshold", "failure-threshold"}
+
+// ImportApplications converts every Avassa application in the yaml stream into Score workloads, one per service.
+// Fields that generate sets from annotations become annotations, Avassa variables in env and cmd are escaped so that
+// Score does not expand them, config map mounts become files, and everything else is kept in x-avassa.
+func ImportApplications(raw []byte, diags *Diagnostics) ([]ScoreWorkload, error) {
+	var out []ScoreWorkload
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	for i := 0; ; i++ {
+		var app map[string]interface{}
+		if err := dec.Decode(&app); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("document %d: failed to decode application: %w", i, err)
+		} else if app == nil {
+			continue
+		}
+		workloads, err := importApplication(app, diags)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		out = append(out, workloads...)
+	}
+	return out, nil
+}
+
+func importApplication(app map[string]interface{}, diags *Diagnostics) ([]ScoreWorkload, error) {
+	appName := asString(app["name"])
+	if appName == "" {
+		return nil, fmt.Errorf("application: missing name")
+	}
+	services, _ := app["services"].([]interface{})
+	if len(services) == 0 {
+		return nil, fmt.Errorf("application: %s: services: expected a non-empty list", appName)
+	}
+
+	rest := maps.Clone(app)
+	delete(rest, "name")
+	delete(rest, "services")
+	annotations := map[string]interface{}{}
+	if v, ok := rest["version"]; ok {
+		annotations["avassa.io/version"] = asString(v)
+		delete(rest, "version")
+	}
+	if v, ok := rest["on-mutable-variable-change"]; ok {
+		if asString(v) != defaultOnMutableVariableChange {
+			annotations["avassa.on-mutable-variable-change"] = asString(v)
+		}
+		delete(rest, "on-mutable-variable-change")
+	}
+	if network, ok := rest["network"].(map[string]interface{}); ok && len(network) == 1 {
+		if v, ok := network["shared-application-network"].(string); ok {
+			annotations["avassa.network"] = v
+			delete(rest, "network")
+		}
+	}
+	labels, ok := rest["labels"].(map[string]interface{})
+	if ok {
+		delete(rest, "labels")
+	}
+	if len(services) > 1 {
+		diags.Warn(appName, "services", "the application has %d services, each becomes a workload and application of its own", len(services))
+	}
+
+	out := make([]ScoreWorkload, 0, len(services))
+	for i, rawService := range services {
+		svc, ok := rawService.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("application: %s: services[%d]: expected a map", appName, i)
+		}
+		workloadName := appName
+		if len(services) > 1 {
+			workloadName = asString(svc["name"])
+			if !strings.HasPrefix(workloadName, appName+"-") {
+				workloadName = appName + "-" + workloadName
+			}
+		}
+		workload := ScoreWorkload{
+			APIVersion: scoreAPIVersion,
+			Metadata:   map[string]interface{}{"name": sanitizeName(workloadName)},
+			Containers: map[string]ScoreContainer{},
+		}
+		if len(labels) > 0 {
+			workload.Metadata["labels"] = labels
+		}
+		workloadAnnotations := maps.Clone(annotations)
+		if err := importService(&workload, svc, workloadAnnotations); err != nil {
+			return nil, fmt.Errorf("application: %s: services[%d]: %w", appName, i, err)
+		}
+		if len(workloadAnnotations) > 0 {
+			workload.Metadata["annotations"] = workloadAnnotations
+		}
+		if len(rest) > 0 {
+			if workload.Avassa == nil {
+				workload.Avassa = map[string]interface{}{}
+			}
+			maps.Copy(workload.Avassa, rest)
+		}
+		out = append(out, workload)
+	}
+	return out, nil
+}
+
+// importService maps the service and its containers into the workload.
+func importService(workload *ScoreWorkload, svc map[string]interface{}, annotations map[string]interface{}) error {
+	rest := maps.Clone(svc)
+	delete(rest, "containers")
+	if name := asString(rest["name"]); name == serviceName(workload.Name()) {
+		delete(rest, "name")
+	}
+
+	switch mode := asString(rest["mode"]); mode {
+	case "", serviceModeReplicated:
+		delete(rest, "mode")
+		if v, ok := rest["replicas"]; ok {
+			if asInt(v, 1) != 1 {
+				annotations["avassa.replicas"] = asString(v)
+			}
+			delete(rest, "replicas")
+		}
+	default:
+		annotations["avassa.io/mode"] = mode
+		delete(rest, "mode")
+	}
+	if v, ok := rest["share-pid-namespace"].(bool); ok {
+		if v {
+			annotations["avassa.share-pid-namespace"] = "true"
+		}
+		delete(rest, "share-pid-namespace")
+	}
+
+	if variables, ok := rest["variables"].([]interface{}); ok {
+		var remaining []interface{}
+		for _, raw := range variables {
+			v, _ := raw.(map[string]interface{})
+			name := asString(v["name"])
+			if _, hasValue := v["value"]; len(v) == 2 && hasValue && variableNameRe.MatchString(name) {
+				annotations[variableAnnotationPrefix+name] = asString(v["value"])
+			} else {
+				remaining = append(remaining, raw)
+			}
+		}
+		if len(remaining) > 0 {
+			rest["variables"] = remaining
+		} else {
+			delete(rest, "variables")
+		}
+	}
+
+	configMaps := importConfigMaps(rest["volumes"])
+	unmapped := map[string]bool{}
+	containers, _ := svc["containers"].([]interface{})
+	if len(containers) == 0 {
+		return fmt.Errorf("containers: expected a non-empty list")
+	}
+	for i, raw := range containers {
+		c, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("containers[%d]: expected a map", i)
+		}
+		name := asString(c["name"])
+		if name == "" {
+			return fmt.Errorf("containers[%d]: missing name", i)
+		}
+		workload.Containers[name] = importContainer(c, configMaps, unmapped, annotations)
+	}
+
+	// Config maps that are only mounted as files are replaced by the files
+	if volumes, ok := rest["volumes"].([]interface{}); ok {
+		remaining := slices.DeleteFunc(slices.Clone(volumes), func(raw interface{}) bool {
+			v, _ := raw.(map[string]interface{})
+			_, isConfigMap := configMaps[asString(v["name"])]
+			return isConfigMap && !unmapped[asString(v["name"])]
+		})
+		if len(remaining) > 0 {
+			rest["volumes"] = remaining
+		} else {
+			delete(rest, "volumes")
+		}
+	}
+
+	if len(rest) > 0 {
+		workload.Avassa = map[string]interface{}{avassaServiceExtensionKey: rest}
+	}
+	return nil
+}
+
+// configMapItem is a config map item that only holds data or verbatim data and optionally a file mode.
+type configMapItem struct {
+	data, mode string
+	verbatim   bool
+}
+
+// importConfigMaps returns the items of the config map volumes that only hold data or verbatim data, by volume and item
+// name.
+func importConfigMaps(rawVolumes interface{}) map[string]map[string]configMapItem {
+	out := map[string]map[string]configMapItem{}
+	volumes, _ := rawVolumes.([]interface{})
+	for _, raw := range volumes {
+		v, _ := raw.(map[string]interface{})
+		configMap, ok := v["config-map"].(map[string]interface{})
+		if !ok || len(v) != 2 || len(configMap) != 1 {
+			continue
+		}
+		rawItems, _ := configMap["items"].([]interface{})
+		items := map[string]configMapItem{}
+		for _, rawItem := range rawItems {
+			item, _ := rawItem.(map[string]interface{})
+			data, ok := item["data"].(string)
+			verbatim, isVerbatim := item["data-verbatim"].(string)
+			mode, hasMode := item["file-mode"].(string)
+			if _, hasName := item["name"]; ok == isVerbatim || !hasName || len(item) != 2 && !(hasMode && len(item) == 3) {
+				items = nil
+				break
+			}
+			if isVerbatim {
+				data = verbatim
+			}
+			items[asString(item["name"])] = configMapItem{data: data, mode: mode, verbatim: isVerbatim}
+		}
+		if items != nil {
+			out[asString(v["name"])] = items
+		}
+	}
+	return out
+}
+
+// importContainer maps an Avassa container into a Score container. Config map volumes with a mount that can't be turned
+// into files are recorded in unmapped.
+func importContainer(c map[string]interface{}, configMaps map[string]map[string]configMapItem, unmapped map[string]bool, annotations map[string]interface{}) ScoreContainer {
+	rest := maps.Clone(c)
+	name := asString(rest["name"])
+	delete(rest, "name")
+	out := ScoreContainer{Image: asString(rest["image"])}
+	delete(rest, "image")
+
+	if cmd, ok := rest["cmd"].([]interface{}); ok {
+		for _, part := range cmd {
+			out.Command = append(out.Command, escapePlaceholders(asString(part)))
+		}
+		delete(rest, "cmd")
+	}
+	if env, ok := rest["env"].(map[string]interface{}); ok {
+		out.Variables = make(map[string]string, len(env))
+		for k, v := range env {
+			out.Variables[k] = escapePlaceholders(asString(v))
+		}
+		delete(rest, "env")
+	}
+	for key, def := range map[string]interface{}{
+		"container-log-size":    defaultContainerLogSize,
+		"shutdown-timeout":      defaultShutdownTimeout,
+		"container-log-archive": false,
+	} {
+		if v, ok := rest[key]; ok && v == def {
+			delete(rest, key)
+		}
+	}
+
+	if mounts, ok := rest["mounts"].([]interface{}); ok {
+		var remaining []interface{}
+		for _, raw := range mounts {
+			if files, ok := importConfigMapMount(raw, configMaps); ok {
+				if out.Files == nil {
+					out.Files = map[string]scoretypes.ContainerFile{}
+				}
+				maps.Copy(out.Files, files)
+			} else {
+				remaining = append(remaining, raw)
+				m, _ := raw.(map[string]interface{})
+				unmapped[asString(m["volume-name"])] = true
+			}
+		}
+		if len(remaining) > 0 {
+			rest["mounts"] = remaining
+		} else {
+			delete(rest, "mounts")
+		}
+	}
+
+	if probes, ok := rest["probes"].(map[string]interface{}); ok {
+		probes = maps.Clone(probes)
+		importProbes(&out, name, probes, annotations)
+		if len(probes) > 0 {
+			rest["probes"] = probes
+		} else {
+			delete(rest, "probes")
+		}
+	}
+
+	if len(rest) > 0 {
+		out.Avassa = rest
+	}
+	return out
+}
+
+// importConfigMapMount returns the files of a mount that only mounts config map items by path.
+func importConfigMapMount(raw interface{}, configMaps map[string]map[string]configMapItem) (map[string]scoretypes.ContainerFile, bool) {
+	m, _ := raw.(map[string]interface{})
+	items, ok := configMaps[asString(m["volume-name"])]
+	files, _ := m["files"].([]interface{})
+	if !ok || len(m) != 2 || len(files) == 0 {
+		return nil, false
+	}
+	out := make(map[string]scoretypes.ContainerFile, len(files))
+	for _, rawFile := range files {
+		f, _ := rawFile.(map[string]interface{})
+		item, ok := items[asString(f["name"])]
+		target := asString(f["mount-path"])
+		if !ok || target == "" || len(f) != 2 {
+			return nil, false
+		}
+		// Verbatim data is kept as is, while Avassa variables in data are escaped so that Score does not expand them
+		file := scoretypes.ContainerFile{Content: &item.data, NoExpand: &item.verbatim}
+		if !item.verbatim {
+			content := escapePlaceholders(item.data)
+			file.Content, file.NoExpand = &content, nil
+		}
+		if item.mode != "" {
+			mode := "0" + item.mode
+			file.Mode = &mode
+		}
+		out[target] = file
+	}
+	return out, true
+}
+
+// importProbes maps the liveness and readiness probes onto the Score probes and the timing, tcp probes and a startup
+// probe that repeats one of them onto per-container annotations. Whatever is left stays in probes.
+func importProbes(out *ScoreContainer, containerName string, probes map[string]interface{}, annotations map[string]interface{}) {
+	prefix := "avassa.containers." + containerName + ".probes."
+	checks := map[string]map[string]interface{}{}
+	for _, kind := range []string{probeLiveness, probeReadiness, probeStartup} {
+		p, ok := probes[kind].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rest := maps.Clone(p)
+		mapped := false
+		switch {
+		case len(onlyKeys(rest["tcp"], "port")) == 1:
+			annotations[prefix+kind+".tcp-port"] = asString(rest["tcp"].(map[string]interface{})["port"])
+			delete(rest, "tcp")
+			mapped = true
+		case kind == probeStartup:
+			for _, from := range []string{probeLiveness, probeReadiness} {
+				if check := probeCheck(rest); check != nil && reflect.DeepEqual(check, checks[from]) {
+					annotations[prefix+kind+".from"] = from
+					delete(rest, "http")
+					delete(rest, "exec")
+					mapped = true
+					break
+				}
+			}
+		default:
+			if probe := importProbeCheck(rest); probe != nil {
+				checks[kind] = probeCheck(rest)
+				delete(rest, "http")
+				delete(rest, "exec")
+				mapped = true
+				if kind == probeLiveness {
+					out.LivenessProbe = probe
+				} else {
+					out.ReadinessProbe = probe
+				}
+			}
+		}
+		if !mapped {
+			continue
+		}
+		for _, key := range probeTimingKeys {
+			if v, ok := rest[key]; ok {
+				annotations[prefix+kind+"."+key] = asString(v)
+				delete(rest, key)
+			}
+		}
+		if len(rest) > 0 {
+			probes[kind] = rest
+		} else {
+			delete(probes, kind)
+		}
+	}
+}
+
+// probeCheck returns the http or exec check of the probe.
+func probeCheck(p map[string]interface{}) map[string]interface{} {
+	for _, key := range []string{"http", "exec"} {
+		if v, ok := p[key]; ok {
+			return map[string]interface{}{key: v}
+		}
+	}
+	return nil
+}
+
+// importProbeCheck converts an http or exec check into a Score probe, or returns nil if it has fields Score can't
+// express.
+func importProbeCheck(p map[string]interface{}) *scoretypes.ContainerProbe {
+	if _, ok := p["http"]; ok {
+		if _, ok := p["exec"]; ok {
+			return nil
+		}
+		http := onlyKeys(p["http"], "scheme", "host", "path", "port", "request-headers")
+		path, hasPath := http["path"].(string)
+		port := asInt(http["port"], 0)
+		if http == nil || !hasPath || port <= 0 {
+			return nil
+		}
+		out := &scoretypes.HttpProbe{Path: path, Port: port}
+		if v, ok := http["scheme"]; ok {
+			scheme := scoretypes.HttpProbeScheme(strings.ToUpper(asString(v)))
+			if scheme != scoretypes.HttpProbeSchemeHTTP && scheme != scoretypes.HttpProbeSchemeHTTPS {
+				return nil
+			}
+			out.Scheme = &scheme
+		}
+		if v, ok := http["host"]; ok {
+			host := asString(v)
+			out.Host = &host
+		}
+		if headers, ok := http["request-headers"].(map[string]interface{}); ok {
+			for _, name := range slices.Sorted(maps.Keys(headers)) {
+				out.HttpHeaders = append(out.HttpHeaders, scoretypes.HttpProbeHttpHeadersElem{Name: name, Value: asString(headers[name])})
+			}
+		}
+		return &scoretypes.ContainerProbe{HttpGet: out}
+	}
+	exec := onlyKeys(p["exec"], "cmd")
+	cmd, _ := exec["cmd"].([]interface{})
+	if len(cmd) == 0 {
+		return nil
+	}
+	out := &scoretypes.ExecProbe{}
+	for _, part := range cmd {
+		out.Command = append(out.Command, asString(part))
+	}
+	return &scoretypes.ContainerProbe{Exec: out}
+}
+
+// onlyKeys returns the value as a map if it is one and has no keys other than the given ones, otherwise nil.
+func onlyKeys(v interface{}, keys ...string) map[string]interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for k := range m {
+		if !slices.Contains(keys, k) {
+			return nil
+		}
+	}
+	return m
+}
+
+// escapePlaceholders escapes the value so that Score placeholder substitution returns it unchanged.
+func escapePlaceholders(v string) string {
+	return strings.ReplaceAll(v, "$", "$$")
+}
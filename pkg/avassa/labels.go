@@ -0,0 +1,109 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// systemLabelPrefix is reserved for labels that Avassa assigns itself.
+const systemLabelPrefix = "system/"
+
+// normaliseScoreLabels converts the Score metadata labels into Avassa labels. Unprefixed names get the prefix from the
+// project config when one is set, and values become strings or lists of strings.
+func normaliseScoreLabels(metadata map[string]interface{}, policy LabelPolicy) (map[string]interface{}, error) {
+	raw, _ := metadata["labels"].(map[string]interface{})
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	prefix := strings.TrimSuffix(policy.Prefix, "/")
+	if prefix != "" {
+		if !domainNameRe.MatchString(prefix) {
+			return nil, fmt.Errorf("labels: the prefix '%s' from the project config must be a DNS domain name", policy.Prefix)
+		}
+		prefix += "/"
+	}
+	out := make(map[string]interface{}, len(raw))
+	origin := make(map[string]string, len(raw))
+	for _, name := range slices.Sorted(maps.Keys(raw)) {
+		target := name
+		if prefix != "" && !strings.Contains(name, "/") {
+			target = prefix + name
+		}
+		if other, ok := origin[target]; ok {
+			return nil, fmt.Errorf("labels: '%s' and '%s' both become the label '%s'", other, name, target)
+		}
+		origin[target] = name
+		switch v := raw[name].(type) {
+		case []interface{}:
+			values := make([]string, len(v))
+			for i, item := range v {
+				values[i] = labelValueString(item)
+			}
+			out[target] = values
+		default:
+			out[target] = labelValueString(v)
+		}
+	}
+	return out, nil
+}
+
+// labelValueString formats a scalar label value, Score allows numbers and booleans where Avassa expects strings.
+func labelValueString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return fmt.Sprint(t)
+	default:
+		return asString(t)
+	}
+}
+
+// validateLabels checks the label names and values of the application, including any set through x-avassa.
+func validateLabels(labels map[string]interface{}) error {
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if strings.HasPrefix(name, systemLabelPrefix) {
+			return fmt.Errorf("labels: %s: the '%s' prefix is reserved for labels assigned by Avassa", name, systemLabelPrefix)
+		}
+		if err := validateLabelName(name); err != nil {
+			return fmt.Errorf("labels: %w", err)
+		}
+		var values []interface{}
+		switch v := labels[name].(type) {
+		case []interface{}:
+			values = v
+		case []string:
+			for _, item := range v {
+				values = append(values, item)
+			}
+		default:
+			values = []interface{}{v}
+		}
+		for _, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("labels: %s: '%v' is not a valid label value, expected a string or a list of strings", name, value)
+			}
+			if err := validateLabelValue(s); err != nil {
+				return fmt.Errorf("labels: %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
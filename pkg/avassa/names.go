@@ -0,0 +1,76 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxNameLength is the longest application or service name that Avassa accepts, the length of a dns label.
+const maxNameLength = 63
+
+// nameHashLength is the number of hex characters of the hash appended to truncated names.
+const nameHashLength = 8
+
+// truncateName shortens names over maxNameLength to a prefix followed by a hash of the full name, so that distinct
+// long names stay distinct and the same name always truncates the same way.
+func truncateName(name string) string {
+	if len(name) <= maxNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	prefix := strings.TrimRight(name[:maxNameLength-nameHashLength-1], "-")
+	return prefix + "-" + hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// checkNameCollisions fails when two workloads produce the same Avassa application name, since the second application
+// would silently replace the first. The input maps each Score workload name to its generated application name, which
+// may differ after sanitising, truncating, or an x-avassa name.
+func checkNameCollisions(appNames map[string]string) error {
+	byAppName := make(map[string][]string)
+	for workloadName, appName := range appNames {
+		byAppName[appName] = append(byAppName[appName], workloadName)
+	}
+	var messages []string
+	for _, appName := range slices.Sorted(maps.Keys(byAppName)) {
+		if workloads := byAppName[appName]; len(workloads) > 1 {
+			sort.Strings(workloads)
+			messages = append(messages, fmt.Sprintf("workloads '%s' all produce the application name '%s'", strings.Join(workloads, "', '"), appName))
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("application name collision: %s, rename all but one of them", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// checkServiceNames fails when the application has two services with the same name, for example when a service added
+// through x-avassa reuses the name of the generated service.
+func checkServiceNames(app ApplicationSpec) error {
+	seen := make(map[string]bool, len(app.Services))
+	for _, svc := range app.Services {
+		if seen[svc.Name] {
+			return fmt.Errorf("services: duplicate service name '%s'", svc.Name)
+		}
+		seen[svc.Name] = true
+	}
+	return nil
+}
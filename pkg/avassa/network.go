@@ -0,0 +1,195 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/score-spec/score-go/framework"
+)
+
+// ServiceNetwork is the network of a service.
+type ServiceNetwork struct {
+	OutboundAccess *OutboundAccess `yaml:"outbound-access,omitempty"`
+	// Extra holds any other service network fields set through x-avassa, such as ingress-ip-per-instance.
+	Extra map[string]any `yaml:",inline"`
+}
+
+// OutboundAccess holds the outbound network access rules of a service.
+type OutboundAccess struct {
+	AllowAll      bool              `yaml:"allow-all,omitempty"`
+	DenyAll       bool              `yaml:"deny-all,omitempty"`
+	DefaultAction string            `yaml:"default-action,omitempty"`
+	Rules         map[string]string `yaml:"rules,omitempty"`
+}
+
+// ApplicationResources holds the resource limits of an application.
+type ApplicationResources struct {
+	Network *ApplicationNetworkResources `yaml:"network,omitempty"`
+}
+
+// ApplicationNetworkResources is the per host network bandwidth limit of an application.
+type ApplicationNetworkResources struct {
+	UpstreamBandwidthPerHost   string `yaml:"upstream-bandwidth-per-host,omitempty"`
+	DownstreamBandwidthPerHost string `yaml:"downstream-bandwidth-per-host,omitempty"`
+}
+
+const (
+	verdictAllow = "allow"
+	verdictDeny  = "deny"
+)
+
+// outboundAddressKeys are the resource outputs, or params when there is no such output, that hold the address a
+// workload connects to through a resource.
+var outboundAddressKeys = []string{"host", "address"}
+
+// buildOutboundAccess computes the outbound access rules of the workload's service. Rules are only generated when a
+// default action is set, by the avassa.outbound-access.default-action annotation or the project network policy. In
+// that case every resource of the workload with a host or address contributes an allow rule. Explicit
+// avassa.outbound-access.rules require a default action, as either choice changes what the rules mean: a single deny
+// rule with a deny default blocks all egress.
+func buildOutboundAccess(currentState *projectState, workloadName string, annotations map[string]interface{}, policy NetworkPolicy) (*OutboundAccess, error) {
+	defaultAction := strings.TrimSpace(asString(annotations["avassa.outbound-access.default-action"]))
+	if defaultAction == "" {
+		defaultAction = policy.OutboundDefaultAction
+	}
+	explicitRules := splitList(asString(annotations["avassa.outbound-access.rules"]))
+	if defaultAction == "" && len(explicitRules) == 0 {
+		return nil, nil
+	} else if defaultAction == "" {
+		return nil, fmt.Errorf("outbound-access: rules: a default action is required, set the avassa.outbound-access.default-action annotation or network.outbound-default-action in the project config")
+	} else if defaultAction != verdictAllow && defaultAction != verdictDeny {
+		return nil, fmt.Errorf("outbound-access: default-action: '%s' must be '%s' or '%s'", defaultAction, verdictAllow, verdictDeny)
+	}
+
+	out := &OutboundAccess{DefaultAction: defaultAction, Rules: map[string]string{}}
+
+	spec := currentState.Workloads[workloadName].Spec
+	resNames := make([]string, 0, len(spec.Resources))
+	for resName := range spec.Resources {
+		resNames = append(resNames, resName)
+	}
+	slices.Sort(resNames)
+	for _, resName := range resNames {
+		res := spec.Resources[resName]
+		resState, ok := currentState.Resources[framework.NewResourceUid(workloadName, resName, res.Type, res.Class, res.Id)]
+		if !ok {
+			continue
+		}
+		for _, key := range outboundAddressKeys {
+			raw, err := resState.OutputLookup(key)
+			if err != nil || asString(raw) == "" {
+				raw = resState.Params[key]
+			}
+			if v := asString(raw); v != "" {
+				destination, err := normaliseDestination(v)
+				if err != nil {
+					return nil, fmt.Errorf("outbound-access: resource: %s: %s: %w", resName, key, err)
+				}
+				out.Rules[destination] = verdictAllow
+			}
+		}
+	}
+
+	// Explicit rules are applied last so that they can deny a derived destination
+	for _, rule := range explicitRules {
+		destination, verdict, ok := strings.Cut(rule, "=")
+		if !ok {
+			verdict = verdictAllow
+		}
+		verdict = strings.TrimSpace(verdict)
+		if verdict != verdictAllow && verdict != verdictDeny {
+			return nil, fmt.Errorf("outbound-access: rules: '%s' must have the verdict '%s' or '%s'", rule, verdictAllow, verdictDeny)
+		}
+		destination, err := normaliseDestination(strings.TrimSpace(destination))
+		if err != nil {
+			return nil, fmt.Errorf("outbound-access: rules: %w", err)
+		}
+		out.Rules[destination] = verdict
+	}
+	if len(out.Rules) == 0 {
+		out.Rules = nil
+		if defaultAction == verdictDeny {
+			return &OutboundAccess{DenyAll: true}, nil
+		}
+		return &OutboundAccess{AllowAll: true}, nil
+	}
+	return out, nil
+}
+
+// normaliseDestination converts an address, url, ip or network into an outbound access destination. Single ip
+// addresses become host prefixes and ports are dropped.
+func normaliseDestination(in string) (string, error) {
+	v := in
+	if strings.Contains(v, "://") {
+		if u, err := url.Parse(v); err == nil && u.Hostname() != "" {
+			v = u.Hostname()
+		}
+	} else if host, _, err := net.SplitHostPort(v); err == nil {
+		v = host
+	}
+	if _, network, err := net.ParseCIDR(v); err == nil {
+		return network.String(), nil
+	} else if ip := net.ParseIP(v); ip != nil {
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	} else if domainNameRe.MatchString(v) {
+		return strings.ToLower(v), nil
+	}
+	return "", fmt.Errorf("'%s' is not a valid ip, network or host name", in)
+}
+
+// applyBandwidthLimits sets the per-host application bandwidth limits that were not set through x-avassa, from the
+// avassa.resources.network.<direction>-bandwidth-per-host annotations or else the project network policy, and
+// validates the result.
+func applyBandwidthLimits(app *ApplicationSpec, annotations map[string]interface{}, policy NetworkPolicy) error {
+	if app.Resources == nil {
+		app.Resources = &ApplicationResources{}
+	}
+	if app.Resources.Network == nil {
+		app.Resources.Network = &ApplicationNetworkResources{}
+	}
+	limits := app.Resources.Network
+	for _, l := range []struct {
+		key    string
+		target *string
+		policy string
+	}{
+		{"upstream-bandwidth-per-host", &limits.UpstreamBandwidthPerHost, policy.UpstreamBandwidthPerHost},
+		{"downstream-bandwidth-per-host", &limits.DownstreamBandwidthPerHost, policy.DownstreamBandwidthPerHost},
+	} {
+		if *l.target == "" {
+			*l.target = firstNonEmpty(strings.TrimSpace(asString(annotations["avassa.resources.network."+l.key])), l.policy)
+		}
+		if *l.target != "" {
+			if err := validateBandwidth(*l.target); err != nil {
+				return fmt.Errorf("resources: network: %s: %w", l.key, err)
+			}
+		}
+	}
+	if *limits == (ApplicationNetworkResources{}) {
+		app.Resources.Network = nil
+	}
+	if *app.Resources == (ApplicationResources{}) {
+		app.Resources = nil
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Placement holds the placement rules of a service.
+type Placement struct {
+	MatchHostLabels       string    `yaml:"match-host-labels,omitempty"`
+	PreferredAffinity     *Affinity `yaml:"preferred-affinity,omitempty"`
+	PreferredAntiAffinity *Affinity `yaml:"preferred-anti-affinity,omitempty"`
+}
+
+// Affinity lists the services a service should run next to.
+type Affinity struct {
+	Services []string `yaml:"services"`
+}
+
+// placementFromAnnotations builds the service placement from the avassa.placement.* annotations. The affinity
+// annotations are comma-separated lists of Score workload names or APPNAME.SERVICENAME references.
+func placementFromAnnotations(annotations map[string]interface{}) *Placement {
+	out := &Placement{
+		MatchHostLabels: strings.TrimSpace(asString(annotations["avassa.placement.match-host-labels"])),
+	}
+	if v := splitList(asString(annotations["avassa.placement.preferred-affinity"])); len(v) > 0 {
+		out.PreferredAffinity = &Affinity{Services: v}
+	}
+	if v := splitList(asString(annotations["avassa.placement.preferred-anti-affinity"])); len(v) > 0 {
+		out.PreferredAntiAffinity = &Affinity{Services: v}
+	}
+	if out.MatchHostLabels == "" && out.PreferredAffinity == nil && out.PreferredAntiAffinity == nil {
+		return nil
+	}
+	return out
+}
+
+// resolvePlacementRefs replaces Score workload names in the affinity lists with the service they generate. References
+// containing a '.' are already in APPNAME.SERVICENAME form and are kept as-is.
+func resolvePlacementRefs(placement *Placement, serviceRefs map[string]string) error {
+	if placement == nil {
+		return nil
+	}
+	for field, affinity := range map[string]*Affinity{
+		"preferred-affinity":      placement.PreferredAffinity,
+		"preferred-anti-affinity": placement.PreferredAntiAffinity,
+	} {
+		if affinity == nil {
+			continue
+		}
+		for i, ref := range affinity.Services {
+			if resolved, ok := serviceRefs[ref]; ok {
+				affinity.Services[i] = resolved
+			} else if !strings.Contains(ref, ".") {
+				return fmt.Errorf("placement: %s: unknown workload '%s', use APPNAME.SERVICENAME to refer to services outside of the project", field, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// workloadServiceRefs returns the affinity reference for the service generated by each workload in the state, as seen
+// from the given workload. The workload's own service is referred to by name, all others as APPNAME.SERVICENAME.
+func workloadServiceRefs(currentState *projectState, workloadName string) map[string]string {
+	out := make(map[string]string, len(currentState.Workloads)+1)
+	for name, w := range currentState.Workloads {
+		appName := applicationName(w.Spec.Metadata, name)
+		if name == workloadName {
+			out[name] = serviceName(appName)
+			out[serviceName(appName)] = serviceName(appName)
+		} else {
+			out[name] = appName + "." + serviceName(appName)
+		}
+	}
+	return out
+}
+
+// splitList splits a comma-separated annotation value, dropping empty entries.
+func splitList(in string) []string {
+	var out []string
+	for _, part := range strings.Split(in, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+const (
+	serviceModeReplicated         = "replicated"
+	serviceModeOnePerMatchingHost = "one-per-matching-host"
+)
+
+// applyServiceMode validates the service mode and sets the replica count for replicated services. It runs after the
+// x-avassa extension has been merged so that the mode or replicas may also come from there.
+func applyServiceMode(svc *Service, annotations map[string]interface{}) error {
+	_, hasReplicasAnnotation := annotations["avassa.replicas"]
+	switch svc.Mode {
+	case serviceModeReplicated:
+		if svc.Replicas == nil {
+			replicas := asInt(annotations["avassa.replicas"], 1)
+			svc.Replicas = &replicas
+		}
+	case serviceModeOnePerMatchingHost:
+		if hasReplicasAnnotation || svc.Replicas != nil {
+			return fmt.Errorf("mode: avassa.replicas cannot be set when the mode is '%s'", serviceModeOnePerMatchingHost)
+		}
+		if svc.Placement != nil && (svc.Placement.PreferredAffinity != nil || svc.Placement.PreferredAntiAffinity != nil) {
+			return fmt.Errorf("mode: placement affinity is only valid when the mode is '%s'", serviceModeReplicated)
+		}
+	default:
+		return fmt.Errorf("mode: unsupported mode '%s', expected '%s' or '%s'", svc.Mode, serviceModeReplicated, serviceModeOnePerMatchingHost)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"math"
+
+	scoretypes "github.com/score-spec/score-go/types"
+)
+
+const (
+	probeLiveness  = "liveness"
+	probeReadiness = "readiness"
+	probeStartup   = "startup"
+)
+
+// buildContainerProbes maps the Score liveness and readiness probes of the container and completes them from the
+// (per-container) annotations:
+//
+//	avassa.probes.<probe>.tcp-port           use a tcp probe, Score has no tcp probe
+//	avassa.probes.startup.from               use the Score liveness or readiness probe as startup probe
+//	avassa.probes.<probe>.initial-delay      period, timeout, success-threshold and failure-threshold likewise
+//
+// where <probe> is one of liveness, readiness or startup. Each key can be set for a single container with
+// avassa.containers.<container>.probes.<probe>.<key>.
+func buildContainerProbes(c scoretypes.Container, containerName string, annotations map[string]interface{}) (*Probes, error) {
+	var probes Probes
+	for _, kind := range []string{probeLiveness, probeReadiness, probeStartup} {
+		var spec *Probe
+		switch kind {
+		case probeLiveness:
+			spec = mapScoreProbeToAvassa(c.LivenessProbe)
+		case probeReadiness:
+			spec = mapScoreProbeToAvassa(c.ReadinessProbe)
+		case probeStartup:
+			if v, ok := containerAnnotation(annotations, containerName, "probes.startup.from"); ok {
+				switch from := asString(v); from {
+				case probeLiveness:
+					spec = mapScoreProbeToAvassa(c.LivenessProbe)
+				case probeReadiness:
+					spec = mapScoreProbeToAvassa(c.ReadinessProbe)
+				default:
+					return nil, fmt.Errorf("probes: startup: from: '%s' must be '%s' or '%s'", from, probeLiveness, probeReadiness)
+				}
+				if spec == nil {
+					return nil, fmt.Errorf("probes: startup: from: container has no %s probe", asString(v))
+				}
+			}
+		}
+
+		if v, ok := containerAnnotation(annotations, containerName, "probes."+kind+".tcp-port"); ok {
+			if spec != nil {
+				return nil, fmt.Errorf("probes: %s: tcp-port cannot be combined with another %s probe", kind, kind)
+			}
+			port, err := parseUint(v, 1, math.MaxUint16)
+			if err != nil {
+				return nil, fmt.Errorf("probes: %s: tcp-port: %w", kind, err)
+			}
+			spec = &Probe{TCP: &TCPProbe{Port: port}}
+		}
+
+		if err := applyProbeTiming(spec, kind, containerName, annotations); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case probeLiveness:
+			probes.Liveness = spec
+		case probeReadiness:
+			probes.Readiness = spec
+		case probeStartup:
+			probes.Startup = spec
+		}
+	}
+	if probes.Liveness == nil && probes.Readiness == nil && probes.Startup == nil {
+		return nil, nil
+	}
+	return &probes, nil
+}
+
+// applyProbeTiming sets the optional timing fields of the probe from the annotations. Timing without a probe to apply
+// it to is an error.
+func applyProbeTiming(spec *Probe, kind string, containerName string, annotations map[string]interface{}) error {
+	for _, key := range []string{"initial-delay", "period", "timeout", "success-threshold", "failure-threshold"} {
+		v, ok := containerAnnotation(annotations, containerName, "probes."+kind+"."+key)
+		if !ok {
+			continue
+		}
+		if spec == nil {
+			return fmt.Errorf("probes: %s: %s is set but the container has no %s probe", kind, key, kind)
+		}
+		switch key {
+		case "initial-delay", "period", "timeout":
+			d := asString(v)
+			if err := validateDuration(d); err != nil {
+				return fmt.Errorf("probes: %s: %s: %w", kind, key, err)
+			}
+			switch key {
+			case "initial-delay":
+				spec.InitialDelay = d
+			case "period":
+				spec.Period = d
+			case "timeout":
+				spec.Timeout = d
+			}
+		case "success-threshold", "failure-threshold":
+			n, err := parseUint(v, 1, math.MaxUint32)
+			if err != nil {
+				return fmt.Errorf("probes: %s: %s: %w", kind, key, err)
+			}
+			if key == "success-threshold" {
+				spec.SuccessThreshold = n
+			} else {
+				spec.FailureThreshold = n
+			}
+		}
+	}
+	return nil
+}
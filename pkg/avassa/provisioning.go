@@ -12,18 +12,22 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package provisioners
+package avassa
 
 import (
+    "context"
     "fmt"
     "maps"
 
     "github.com/score-spec/score-go/framework"
-
-    "github.com/score-spec/score-implementation-avassa/internal/state"
 )
 
-func ProvisionResources(currentState *state.State) (*state.State, error) {
+// provisionFunc provisions a single resource by setting its outputs. It returns false if it does not handle the resource.
+type provisionFunc func(ctx context.Context, resUid framework.ResourceUid, resState *framework.ScoreResourceState[resourceExtras]) (bool, error)
+
+// provisionResources provisions the resources in dependency order with the first provisioner that handles each one.
+// Resources that no provisioner handles get empty outputs.
+func provisionResources(ctx context.Context, currentState *projectState, provisioners ...provisionFunc) (*projectState, error) {
 	out := currentState
 
 	// provision in sorted order
@@ -51,11 +55,19 @@ func ProvisionResources(currentState *state.State) (*state.State, error) {
 		}
 		resState.Params = params
 
-		// ==========================================================================================
-		// TODO: HERE IS WHERE YOU WOULD USE THE RESOURCE TYPE, CLASS, ID, AND PARAMS TO PROVISION IT
-		// ==========================================================================================
+		// the first provisioner that handles the resource sets its outputs
+		handled := false
+		for _, provision := range provisioners {
+			if handled, err = provision(ctx, resUid, &resState); err != nil {
+				return nil, fmt.Errorf("%s: failed to provision resource: %w", resUid, err)
+			} else if handled {
+				break
+			}
+		}
 
-		resState.Outputs = map[string]interface{}{}
+		if !handled {
+			resState.Outputs = map[string]interface{}{}
+		}
 		out.Resources[resUid] = resState
 	}
 
@@ -0,0 +1,138 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// UserNamespace controls the user namespace of a container.
+type UserNamespace struct {
+	Host bool `yaml:"host"`
+}
+
+// ContainerSecurity holds the security settings of a container.
+type ContainerSecurity struct {
+	AppArmor *SecurityToggle `yaml:"apparmor,omitempty"`
+	SELinux  *SecurityToggle `yaml:"selinux,omitempty"`
+}
+
+// SecurityToggle turns a security feature of a container off.
+type SecurityToggle struct {
+	Disabled bool `yaml:"disabled"`
+}
+
+// avassaCapabilities is the additional-capabilities enumeration of the application spec.
+var avassaCapabilities = []string{
+	"chown", "dac-override", "dac-read-search", "fowner", "fsetid", "kill", "setgid", "setuid", "setpcap",
+	"linux-immutable", "net-bind-service", "net-broadcast", "net-admin", "net-raw", "ipc-lock", "ipc-owner",
+	"sys-module", "sys-rawio", "sys-chroot", "sys-ptrace", "sys-pacct", "sys-admin", "sys-boot", "sys-nice",
+	"sys-resource", "sys-time", "sys-tty-config", "mknod", "lease", "audit-write", "audit-control", "setfcap",
+	"mac-override", "mac-admin", "syslog", "wake-alarm", "block-suspend", "audit-read",
+}
+
+// unprivilegedCapabilities are the capabilities container runtimes grant by default. Any other capability is
+// privileged and must be allowed by the project security policy.
+var unprivilegedCapabilities = []string{
+	"chown", "dac-override", "fowner", "fsetid", "kill", "setgid", "setuid", "setpcap", "net-bind-service",
+	"net-raw", "sys-chroot", "mknod", "audit-write", "setfcap",
+}
+
+var userRe = regexp.MustCompile(`^([0-9]+)(:([0-9]+))?$`)
+
+// applyContainerSecurity sets the security related container fields from the (per-container) annotations:
+//
+//	avassa.additional-capabilities      comma-separated, e.g. NET_ADMIN, CAP_SYS_TIME or net-admin
+//	avassa.user                         uid or uid:gid
+//	avassa.user-namespace.host          true to run in the host user namespace
+//	avassa.security.apparmor.disabled   true to disable apparmor, likewise for selinux
+func applyContainerSecurity(ac *Container, containerName string, annotations map[string]interface{}) {
+	if v, ok := containerAnnotation(annotations, containerName, "additional-capabilities"); ok {
+		ac.AdditionalCapabilities = splitList(asString(v))
+	}
+	if v, ok := containerAnnotation(annotations, containerName, "user"); ok {
+		ac.User = strings.TrimSpace(asString(v))
+	}
+	if v, ok := containerAnnotation(annotations, containerName, "user-namespace.host"); ok && asBool(v, false) {
+		ac.UserNamespace = &UserNamespace{Host: true}
+	}
+	var security ContainerSecurity
+	if v, ok := containerAnnotation(annotations, containerName, "security.apparmor.disabled"); ok && asBool(v, false) {
+		security.AppArmor = &SecurityToggle{Disabled: true}
+	}
+	if v, ok := containerAnnotation(annotations, containerName, "security.selinux.disabled"); ok && asBool(v, false) {
+		security.SELinux = &SecurityToggle{Disabled: true}
+	}
+	if security.AppArmor != nil || security.SELinux != nil {
+		ac.Security = &security
+	}
+}
+
+// normaliseCapability converts the common CAP_NET_ADMIN and NET_ADMIN spellings to the Avassa net-admin form.
+func normaliseCapability(in string) string {
+	out := strings.ToLower(strings.TrimSpace(in))
+	out = strings.TrimPrefix(out, "cap_")
+	return strings.ReplaceAll(out, "_", "-")
+}
+
+// enforceSecurityPolicy validates the security settings of every container in the application, after x-avassa has
+// been merged, and rejects privileged capabilities that the project policy does not allow.
+func enforceSecurityPolicy(app *ApplicationSpec, policy SecurityPolicy) error {
+	allowed := make([]string, 0, len(policy.AllowedCapabilities))
+	for _, c := range policy.AllowedCapabilities {
+		allowed = append(allowed, normaliseCapability(c))
+	}
+	for si, svc := range app.Services {
+		for ci, c := range svc.Containers {
+			for i, raw := range c.AdditionalCapabilities {
+				capability := normaliseCapability(raw)
+				if !slices.Contains(avassaCapabilities, capability) {
+					return fmt.Errorf("container: %s: additional-capabilities: unknown capability '%s'", c.Name, raw)
+				}
+				if !slices.Contains(unprivilegedCapabilities, capability) && !slices.Contains(allowed, capability) {
+					return fmt.Errorf("container: %s: additional-capabilities: '%s' is a privileged capability and must be listed in security.allowed-capabilities of the project config", c.Name, capability)
+				}
+				app.Services[si].Containers[ci].AdditionalCapabilities[i] = capability
+			}
+			if c.User != "" {
+				if err := validateUser(c.User); err != nil {
+					return fmt.Errorf("container: %s: user: %w", c.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateUser checks the uint16 | uid-gid format of the container user.
+func validateUser(v string) error {
+	m := userRe.FindStringSubmatch(v)
+	if m == nil {
+		return fmt.Errorf("'%s' must be a numeric uid or uid:gid", v)
+	}
+	for _, id := range []string{m[1], m[3]} {
+		if id == "" {
+			continue
+		}
+		if n, err := strconv.ParseUint(id, 10, 32); err != nil || (m[3] == "" && n > 65535) {
+			return fmt.Errorf("'%s' is out of range", v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UpgradeFrom describes how an application is upgraded from the versions that match VersionRegexp.
+type UpgradeFrom struct {
+	Method        string               `yaml:"method"`
+	VersionRegexp string               `yaml:"version-regexp"`
+	Services      []UpgradeFromService `yaml:"services,omitempty"`
+}
+
+// UpgradeFromService maps a service of the older application onto a service of the new one.
+type UpgradeFromService struct {
+	Name                string `yaml:"name"`
+	HealthyTime         string `yaml:"healthy-time,omitempty"`
+	InstancesInParallel *int   `yaml:"instances-in-parallel,omitempty"`
+}
+
+// DelayedShutdown delays the shutdown of a service.
+type DelayedShutdown struct {
+	Timeout              string `yaml:"timeout"`
+	MaxNumberOfInstances *int   `yaml:"max-number-of-instances,omitempty"`
+}
+
+const (
+	upgradeMethodStopAndRestart = "stop-and-restart"
+	upgradeMethodPerService     = "per-service"
+)
+
+// upgradeFromAnnotations builds a single upgrade-from entry for the application from the annotations:
+//
+//	avassa.upgrade-from.method                  stop-and-restart or per-service
+//	avassa.upgrade-from.version-regexp          versions this entry upgrades from, defaults to .*
+//	avassa.upgrade-from.instances-in-parallel   per-service only, instances of the service upgraded at once
+//	avassa.upgrade-from.healthy-time            per-service only, time to wait after each set of instances
+func upgradeFromAnnotations(annotations map[string]interface{}, svcName string) ([]UpgradeFrom, error) {
+	method := strings.TrimSpace(asString(annotations["avassa.upgrade-from.method"]))
+	if method == "" {
+		for _, key := range []string{"version-regexp", "instances-in-parallel", "healthy-time"} {
+			if _, ok := annotations["avassa.upgrade-from."+key]; ok {
+				return nil, fmt.Errorf("upgrade-from: %s is set but avassa.upgrade-from.method is not", key)
+			}
+		}
+		return nil, nil
+	}
+	out := UpgradeFrom{
+		Method:        method,
+		VersionRegexp: firstNonEmpty(asString(annotations["avassa.upgrade-from.version-regexp"]), ".*"),
+	}
+	svc := UpgradeFromService{Name: svcName}
+	if v, ok := annotations["avassa.upgrade-from.instances-in-parallel"]; ok {
+		n, err := parseUint(v, 1, math.MaxUint32)
+		if err != nil {
+			return nil, fmt.Errorf("upgrade-from: instances-in-parallel: %w", err)
+		}
+		svc.InstancesInParallel = &n
+	}
+	if v, ok := annotations["avassa.upgrade-from.healthy-time"]; ok {
+		svc.HealthyTime = strings.TrimSpace(asString(v))
+	}
+	if svc.InstancesInParallel != nil || svc.HealthyTime != "" {
+		out.Services = []UpgradeFromService{svc}
+	}
+	return []UpgradeFrom{out}, nil
+}
+
+// validateUpgradeFrom checks the upgrade-from entries of the application, after x-avassa has been merged.
+func validateUpgradeFrom(entries []UpgradeFrom) error {
+	for i, entry := range entries {
+		if entry.Method != upgradeMethodStopAndRestart && entry.Method != upgradeMethodPerService {
+			return fmt.Errorf("upgrade-from: %d: method: '%s' must be '%s' or '%s'", i, entry.Method, upgradeMethodStopAndRestart, upgradeMethodPerService)
+		}
+		if _, err := regexp.Compile(entry.VersionRegexp); err != nil || entry.VersionRegexp == "" {
+			return fmt.Errorf("upgrade-from: %d: version-regexp: '%s' is not a valid regular expression", i, entry.VersionRegexp)
+		}
+		if len(entry.Services) > 0 && entry.Method != upgradeMethodPerService {
+			return fmt.Errorf("upgrade-from: %d: services can only be set when the method is '%s'", i, upgradeMethodPerService)
+		}
+		for _, svc := range entry.Services {
+			if svc.HealthyTime == "" {
+				continue
+			}
+			if svc.InstancesInParallel == nil {
+				return fmt.Errorf("upgrade-from: %d: services: %s: healthy-time requires instances-in-parallel", i, svc.Name)
+			} else if err := validateDuration(svc.HealthyTime); err != nil {
+				return fmt.Errorf("upgrade-from: %d: services: %s: healthy-time: %w", i, svc.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// delayedShutdownFromAnnotations builds the service delayed-shutdown from the avassa.delayed-shutdown.timeout and
+// avassa.delayed-shutdown.max-number-of-instances annotations.
+func delayedShutdownFromAnnotations(annotations map[string]interface{}) (*DelayedShutdown, error) {
+	timeout := strings.TrimSpace(asString(annotations["avassa.delayed-shutdown.timeout"]))
+	rawMax, hasMax := annotations["avassa.delayed-shutdown.max-number-of-instances"]
+	if timeout == "" {
+		if hasMax {
+			return nil, fmt.Errorf("delayed-shutdown: max-number-of-instances is set but avassa.delayed-shutdown.timeout is not")
+		}
+		return nil, nil
+	}
+	out := &DelayedShutdown{Timeout: timeout}
+	if hasMax {
+		n, err := parseUint(rawMax, 0, math.MaxUint32)
+		if err != nil {
+			return nil, fmt.Errorf("delayed-shutdown: max-number-of-instances: %w", err)
+		}
+		out.MaxNumberOfInstances = &n
+	}
+	return out, nil
+}
+
+// parseCommand parses a command annotation, either a yaml list such as ["sh", "-c", "drain && exit"] or a plain
+// whitespace separated command.
+func parseCommand(v string) ([]string, error) {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "[") {
+		var out []string
+		if err := yaml.Unmarshal([]byte(v), &out); err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid list of strings: %w", v, err)
+		}
+		return out, nil
+	}
+	return strings.Fields(v), nil
+}
+
+// validateDelayedShutdown checks the delayed shutdown settings of the service and its containers, after x-avassa has
+// been merged.
+func validateDelayedShutdown(svc Service) error {
+	if svc.DelayedShutdown != nil {
+		if err := validateDuration(svc.DelayedShutdown.Timeout); err != nil {
+			return fmt.Errorf("delayed-shutdown: timeout: %w", err)
+		}
+	}
+	for _, c := range svc.Containers {
+		if len(c.DelayedShutdownCmd) > 0 && svc.DelayedShutdown == nil {
+			return fmt.Errorf("container: %s: delayed-shutdown-cmd requires the service delayed-shutdown, set avassa.delayed-shutdown.timeout", c.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/score-spec/score-go/framework"
+)
+
+// Variable is a service variable, with a plain value or one read from a vault secret.
+type Variable struct {
+	Name                 string       `yaml:"name"`
+	Value                string       `yaml:"value,omitempty"`
+	ValueFromVaultSecret *VaultSecret `yaml:"value-from-vault-secret,omitempty"`
+}
+
+// VaultSecret refers to a key of a vault secret.
+type VaultSecret struct {
+	Vault      string `yaml:"vault"`
+	Secret     string `yaml:"secret"`
+	Key        string `yaml:"key"`
+	FromTenant string `yaml:"from-tenant,omitempty"`
+}
+
+// variableAnnotationPrefix is the annotation prefix used to declare explicit service variables.
+const variableAnnotationPrefix = "avassa.io/variable."
+
+var variableNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// serviceVariables collects the service variables of the workload and rewrites the container env to reference them.
+// Explicit avassa.io/variable.<NAME> annotations always become variables. A container variable is hoisted into a
+// variable of the same name when its value is made only of non-secret resource outputs, and is then set in the env as
+// ${NAME}. Variables that would clash with a different value are left inline.
+func serviceVariables(currentState *projectState, workloadName string, sf func(string) (string, error)) ([]Variable, map[string]map[string]string, error) {
+	spec := currentState.Workloads[workloadName].Spec
+	values := make(map[string]string)
+	for key, v := range workloadAnnotations(spec.Metadata) {
+		name, ok := strings.CutPrefix(key, variableAnnotationPrefix)
+		if !ok {
+			continue
+		}
+		if !variableNameRe.MatchString(name) {
+			return nil, nil, fmt.Errorf("variables: '%s' is not a valid variable name, it must match %s", name, variableNameRe.String())
+		}
+		values[name] = asString(v)
+	}
+
+	secret := secretResourceOutputs(currentState, workloadName)
+	env := make(map[string]map[string]string)
+	containerNames := make([]string, 0, len(spec.Containers))
+	for name := range spec.Containers {
+		containerNames = append(containerNames, name)
+	}
+	sort.Strings(containerNames)
+	for _, containerName := range containerNames {
+		for key, raw := range spec.Containers[containerName].Variables {
+			if !variableNameRe.MatchString(key) {
+				continue
+			}
+			value, ok, err := resolveResourceOutputs(raw, sf, secret)
+			if err != nil {
+				return nil, nil, fmt.Errorf("container: %s: variables: %s: %w", containerName, key, err)
+			} else if !ok {
+				continue
+			}
+			if existing, exists := values[key]; exists && existing != value {
+				continue
+			}
+			values[key] = value
+			if env[containerName] == nil {
+				env[containerName] = make(map[string]string)
+			}
+			env[containerName][key] = "${" + key + "}"
+		}
+	}
+
+	out := make([]Variable, 0, len(values))
+	for name, value := range values {
+		out = append(out, Variable{Name: name, Value: value})
+	}
+	slices.SortFunc(out, func(a, b Variable) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out, env, nil
+}
+
+// resolveResourceOutputs substitutes the placeholders in the value and reports whether every placeholder referred to a
+// resource output that is not secret. Values without placeholders are not considered resource outputs.
+func resolveResourceOutputs(raw string, sf func(string) (string, error), secret map[string]bool) (string, bool, error) {
+	refs, onlyOutputs := 0, true
+	value, err := framework.SubstituteString(raw, func(ref string) (string, error) {
+		refs++
+		parts := framework.SplitRefParts(ref)
+		if len(parts) < 3 || parts[0] != "resources" || secret[parts[1]+"."+parts[2]] {
+			onlyOutputs = false
+		}
+		return sf(ref)
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return value, refs > 0 && onlyOutputs, nil
+}
+
+// secretResourceOutputs returns the <resource>.<output> keys of the workload's resources that the provisioner marked
+// as secret.
+func secretResourceOutputs(currentState *projectState, workloadName string) map[string]bool {
+	out := make(map[string]bool)
+	for resName, res := range currentState.Workloads[workloadName].Spec.Resources {
+		resUid := framework.NewResourceUid(workloadName, resName, res.Type, res.Class, res.Id)
+		for _, key := range currentState.Resources[resUid].Extras.SecretOutputs {
+			out[resName+"."+key] = true
+		}
+	}
+	return out
+}
+
+// mergeServiceVariables adds the generated variables to the service, keeping any variable of the same name that was
+// set through x-avassa.
+func mergeServiceVariables(svc *Service, variables []Variable) {
+	for _, v := range variables {
+		if !slices.ContainsFunc(svc.Variables, func(existing Variable) bool { return existing.Name == v.Name }) {
+			svc.Variables = append(svc.Variables, v)
+		}
+	}
+	slices.SortStableFunc(svc.Variables, func(a, b Variable) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,600 @@
+// Copyright 2024 Humanitec
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avassa
+
+import (
+    "fmt"
+    "maps"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/score-spec/score-go/framework"
+    scoretypes "github.com/score-spec/score-go/types"
+    "gopkg.in/yaml.v3"
+)
+
+// readFileFunc reads the file of a container files[].source.
+type readFileFunc func(path string) ([]byte, error)
+
+// convertWorkload converts the named workload in the state into an Avassa application spec, which it also returns as a
+// manifest map. Warnings about parts of the workload that could not be converted are recorded in diags, which may be
+// nil. File sources are read with readFile.
+func convertWorkload(currentState *projectState, config Config, workloadName string, diags *Diagnostics, readFile readFileFunc) (ApplicationSpec, map[string]interface{}, error) {
+    resOutputs, err := currentState.GetResourceOutputForWorkload(workloadName)
+    if err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("failed to generate outputs: %w", err)
+    }
+    sf := framework.BuildSubstitutionFunction(currentState.Workloads[workloadName].Spec.Metadata, resOutputs)
+
+    spec := currentState.Workloads[workloadName].Spec
+    collectDiagnostics(spec, workloadName, diags)
+    variables, variableEnv, err := serviceVariables(currentState, workloadName, sf)
+    if err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    containers := maps.Clone(spec.Containers)
+    for containerName, container := range containers {
+        if container.Variables, err = convertContainerVariables(container.Variables, sf); err != nil {
+            return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: container: %s: variables: %w", workloadName, containerName, err)
+        }
+        maps.Copy(container.Variables, variableEnv[containerName])
+        if container.Files, err = convertContainerFiles(container.Files, currentState.Workloads[workloadName].File, sf, readFile); err != nil {
+            return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: container: %s: files: %w", workloadName, containerName, err)
+        }
+        containers[containerName] = container
+    }
+
+    // Build Avassa Application spec (subset)
+    serviceRefs := workloadServiceRefs(currentState, workloadName)
+    metadata := maps.Clone(spec.Metadata)
+    if metadata["labels"], err = normaliseScoreLabels(spec.Metadata, config.Labels); err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    app, err := buildAvassaApplication(metadata, workloadName, containers, currentState.Workloads[workloadName].Extras, serviceRefs, sf)
+    if err != nil {
+        return ApplicationSpec{}, nil, err
+    }
+    mergeServiceVariables(&app.Services[0], variables)
+    if err := validateLabels(app.Labels); err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    if err := enforceSecurityPolicy(&app, config.Security); err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    if err := validateContainerDevices(&app); err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+
+    if err := applyBandwidthLimits(&app, workloadAnnotations(spec.Metadata), config.Network); err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+
+    // Outbound access set through x-avassa takes precedence over the generated rules
+    if svc := &app.Services[0]; svc.Network == nil || svc.Network.OutboundAccess == nil {
+        outbound, err := buildOutboundAccess(currentState, workloadName, workloadAnnotations(spec.Metadata), config.Network)
+        if err != nil {
+            return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: %w", workloadName, err)
+        } else if outbound != nil {
+            if svc.Network == nil {
+                svc.Network = &ServiceNetwork{}
+            }
+            svc.Network.OutboundAccess = outbound
+        }
+    }
+
+    // Marshal to YAML then back to map[string]interface{} for downstream pipeline
+    raw, err := yaml.Marshal(app)
+    if err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: failed to serialise avassa manifest: %w", workloadName, err)
+    }
+    var out map[string]interface{}
+    if err := yaml.Unmarshal(raw, &out); err != nil {
+        return ApplicationSpec{}, nil, fmt.Errorf("workload: %s: failed to deserialise avassa manifest: %w", workloadName, err)
+    }
+    return app, out, nil
+}
+
+func convertContainerVariables(input scoretypes.ContainerVariables, sf func(string) (string, error)) (map[string]string, error) {
+	outMap := make(map[string]string, len(input))
+	for key, value := range input {
+		out, err := framework.SubstituteString(value, sf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		outMap[key] = out
+	}
+	return outMap, nil
+}
+
+func convertContainerFiles(input map[string]scoretypes.ContainerFile, scoreFile *string, sf func(string) (string, error), readFile readFileFunc) (map[string]scoretypes.ContainerFile, error) {
+	output := make(map[string]scoretypes.ContainerFile, len(input))
+	for target, file := range input {
+		var content string
+		if file.Content != nil {
+			content = *file.Content
+		} else if file.Source != nil {
+			sourcePath := *file.Source
+			if !filepath.IsAbs(sourcePath) && scoreFile != nil {
+				sourcePath = filepath.Join(filepath.Dir(*scoreFile), sourcePath)
+			}
+			if rawContent, err := readFile(sourcePath); err != nil {
+				return nil, fmt.Errorf("%s: source: failed to read file '%s': %w", target, sourcePath, err)
+			} else {
+				content = string(rawContent)
+			}
+		} else {
+			return nil, fmt.Errorf("%s: missing 'content' or 'source'", target)
+		}
+
+		var err error
+		if file.NoExpand == nil || !*file.NoExpand {
+			content, err = framework.SubstituteString(string(content), sf)
+			if err != nil {
+				return nil, fmt.Errorf("%s: failed to substitute in content: %w", target, err)
+			}
+		}
+		// NoExpand is kept so that the file mapping can tell expanded content from verbatim content
+		file.Source = nil
+		file.Content = &content
+		output[target] = file
+	}
+	return output, nil
+}
+
+// ========================= Avassa helpers and types =========================
+
+// ApplicationSpec is a typed Avassa application spec. Fields set through x-avassa that are not modelled here are kept
+// in Extra, as in the other spec types.
+type ApplicationSpec struct {
+    Name                      string            `yaml:"name"`
+    Version                   string            `yaml:"version,omitempty"`
+    Services                  []Service   `yaml:"services"`
+    OnMutableVariableChange   string            `yaml:"on-mutable-variable-change,omitempty"`
+    Labels                    map[string]any    `yaml:"labels,omitempty"`
+    Network                   *ApplicationNetwork    `yaml:"network,omitempty"`
+    Resources                 *ApplicationResources `yaml:"resources,omitempty"`
+    UpgradeFrom               []UpgradeFrom `yaml:"upgrade-from,omitempty"`
+    // Extra holds any fields set through x-avassa that are not modelled above.
+    Extra                     map[string]any    `yaml:",inline"`
+}
+
+// ApplicationNetwork is the network of an application.
+type ApplicationNetwork struct {
+    SharedApplicationNetwork string `yaml:"shared-application-network"`
+}
+
+// Service is a service of an application.
+type Service struct {
+    Name               string             `yaml:"name"`
+    Mode               string             `yaml:"mode"`
+    Replicas           *int               `yaml:"replicas,omitempty"`
+    SharePidNamespace  bool               `yaml:"share-pid-namespace"`
+    Placement          *Placement   `yaml:"placement,omitempty"`
+    Network            *ServiceNetwork `yaml:"network,omitempty"`
+    DelayedShutdown    *DelayedShutdown `yaml:"delayed-shutdown,omitempty"`
+    Variables          []Variable   `yaml:"variables,omitempty"`
+    Volumes            []any              `yaml:"volumes,omitempty"`
+    Containers         []Container  `yaml:"containers"`
+    Extra              map[string]any     `yaml:",inline"`
+}
+
+// OnMountedFileChange controls what happens to a container when a mounted file changes.
+type OnMountedFileChange struct {
+    Restart bool `yaml:"restart"`
+}
+
+// Container is a container of a service.
+type Container struct {
+    Name                 string                        `yaml:"name"`
+    Mounts               []any                         `yaml:"mounts"`
+    ContainerLogSize     string                        `yaml:"container-log-size,omitempty"`
+    ContainerLogArchive  bool                          `yaml:"container-log-archive,omitempty"`
+    ShutdownTimeout      string                        `yaml:"shutdown-timeout,omitempty"`
+    Image                string                        `yaml:"image"`
+    Cmd                  []string                      `yaml:"cmd,omitempty"`
+    Env                  map[string]string             `yaml:"env,omitempty"`
+    Approle              string                        `yaml:"approle,omitempty"`
+    OnMountedFileChange  *OnMountedFileChange    `yaml:"on-mounted-file-change,omitempty"`
+    Probes               *Probes                 `yaml:"probes,omitempty"`
+    AdditionalCapabilities []string                    `yaml:"additional-capabilities,omitempty"`
+    User                 string                        `yaml:"user,omitempty"`
+    UserNamespace        *UserNamespace          `yaml:"user-namespace,omitempty"`
+    Security             *ContainerSecurity               `yaml:"security,omitempty"`
+    Devices              *Devices                `yaml:"devices,omitempty"`
+    GPU                  *GPU                    `yaml:"gpu,omitempty"`
+    DelayedShutdownCmd   []string                      `yaml:"delayed-shutdown-cmd,omitempty"`
+    Extra                map[string]any                `yaml:",inline"`
+}
+
+// Probes holds the probes of a container.
+type Probes struct {
+    Liveness  *Probe `yaml:"liveness,omitempty"`
+    Readiness *Probe `yaml:"readiness,omitempty"`
+    Startup   *Probe `yaml:"startup,omitempty"`
+}
+
+// Probe is a liveness, readiness or startup probe.
+type Probe struct {
+    HTTP             *HTTPProbe `yaml:"http,omitempty"`
+    TCP              *TCPProbe  `yaml:"tcp,omitempty"`
+    Exec             *ExecProbe `yaml:"exec,omitempty"`
+    InitialDelay     string           `yaml:"initial-delay,omitempty"`
+    Timeout          string           `yaml:"timeout,omitempty"`
+    Period           string           `yaml:"period,omitempty"`
+    SuccessThreshold int              `yaml:"success-threshold,omitempty"`
+    FailureThreshold int              `yaml:"failure-threshold,omitempty"`
+}
+
+// HTTPProbe is a probe that sends an http GET request.
+type HTTPProbe struct {
+    Scheme         string            `yaml:"scheme,omitempty"`
+    Host           string            `yaml:"host,omitempty"`
+    Path           string            `yaml:"path"`
+    Port           int               `yaml:"port"`
+    RequestHeaders map[string]string `yaml:"request-headers,omitempty"`
+}
+
+// TCPProbe is a probe that opens a tcp connection.
+type TCPProbe struct {
+    Port int `yaml:"port"`
+}
+
+// ExecProbe is a probe that runs a command in the container.
+type ExecProbe struct {
+    Cmd []string `yaml:"cmd"`
+}
+
+func buildAvassaApplication(metadata map[string]interface{}, workloadName string, containers map[string]scoretypes.Container, extras Extensions, serviceRefs map[string]string, sf func(string) (string, error)) (ApplicationSpec, error) {
+    // Name
+    appName := applicationName(metadata, workloadName)
+
+    // Annotations (kebab-case under metadata.annotations)
+    annotations := workloadAnnotations(metadata)
+
+    // Top-level fields
+    app := ApplicationSpec{Name: appName}
+    if v := asString(annotations["avassa.on-mutable-variable-change"]); v != "" {
+        app.OnMutableVariableChange = v
+    } else {
+        app.OnMutableVariableChange = "restart-service-instance"
+    }
+
+    if labels, ok := metadata["labels"].(map[string]interface{}); ok && len(labels) > 0 {
+        app.Labels = labels
+    }
+    if v := asString(annotations["avassa.network"]); v != "" {
+        app.Network = &ApplicationNetwork{SharedApplicationNetwork: v}
+    }
+    if v := asString(annotations["avassa.io/version"]); strings.TrimSpace(v) != "" {
+        app.Version = strings.TrimSpace(v)
+    }
+
+    // Service
+    svc := Service{
+        Name:              serviceName(appName),
+        Mode:              firstNonEmpty(strings.TrimSpace(asString(annotations["avassa.io/mode"])), serviceModeReplicated),
+        SharePidNamespace: asBool(annotations["avassa.share-pid-namespace"], false),
+        Placement:         placementFromAnnotations(annotations),
+    }
+    if ds, err := delayedShutdownFromAnnotations(annotations); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    } else {
+        svc.DelayedShutdown = ds
+    }
+    if uf, err := upgradeFromAnnotations(annotations, svc.Name); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    } else {
+        app.UpgradeFrom = uf
+    }
+
+    // Containers (deterministic order)
+    names := make([]string, 0, len(containers))
+    for n := range containers {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    var fileVolumes []any
+    for _, cname := range names {
+        c := containers[cname]
+        env := map[string]string{}
+        for k, v := range c.Variables {
+            env[k] = v
+        }
+        var onMnt *OnMountedFileChange
+        if asBool(annotations["avassa.on-mounted-file-change-restart"], false) {
+            onMnt = &OnMountedFileChange{Restart: true}
+        }
+        ac := Container{
+            Name:                cname,
+            Mounts:              []any{},
+            ContainerLogSize:    firstNonEmpty(asString(annotations["avassa.log-size"]), "100 MB"),
+            ContainerLogArchive: asBool(annotations["avassa.log-archive"], false),
+            ShutdownTimeout:     firstNonEmpty(asString(annotations["avassa.shutdown-timeout"]), "10s"),
+            Image:               c.Image,
+            Env:                 env,
+            OnMountedFileChange: onMnt,
+        }
+        // Map Score command/args -> Avassa cmd
+        if n := len(c.Command) + len(c.Args); n > 0 {
+            combined := make([]string, 0, n)
+            combined = append(combined, c.Command...)
+            combined = append(combined, c.Args...)
+            cmdOut := make([]string, 0, len(combined))
+            for _, part := range combined {
+                p := strings.TrimSpace(part)
+                if p == "" { continue }
+                if sf != nil {
+                    if subst, err := framework.SubstituteString(p, sf); err == nil {
+                        p = subst
+                    } else {
+                        return ApplicationSpec{}, fmt.Errorf("workload: %s: container: %s: cmd: %w", workloadName, cname, err)
+                    }
+                }
+                if t := strings.TrimSpace(p); t != "" {
+                    cmdOut = append(cmdOut, t)
+                }
+            }
+            if len(cmdOut) > 0 {
+                ac.Cmd = cmdOut
+            }
+        }
+        if v := asString(annotations["avassa.approle"]); v != "" {
+            ac.Approle = v
+        }
+
+        // Omit env if empty to reduce noise
+        if len(ac.Env) == 0 {
+            ac.Env = nil
+        }
+
+        // Probes (map Score -> Avassa, plus startup, tcp and timing from annotations)
+        if probes, err := buildContainerProbes(c, cname, annotations); err != nil {
+            return ApplicationSpec{}, fmt.Errorf("workload: %s: container: %s: %w", workloadName, cname, err)
+        } else {
+            ac.Probes = probes
+        }
+        applyContainerSecurity(&ac, cname, annotations)
+        if v, ok := containerAnnotation(annotations, cname, "delayed-shutdown-cmd"); ok {
+            if cmd, err := parseCommand(asString(v)); err != nil {
+                return ApplicationSpec{}, fmt.Errorf("workload: %s: container: %s: delayed-shutdown-cmd: %w", workloadName, cname, err)
+            } else {
+                ac.DelayedShutdownCmd = cmd
+            }
+        }
+        if err := applyContainerDevices(&ac, cname, annotations); err != nil {
+            return ApplicationSpec{}, fmt.Errorf("workload: %s: container: %s: %w", workloadName, cname, err)
+        }
+        if ext := extras.ContainerAvassa[cname]; len(ext) > 0 {
+            if err := mergeAvassaExtension(&ac, ext); err != nil {
+                return ApplicationSpec{}, fmt.Errorf("workload: %s: container: %s: %s: %w", workloadName, cname, avassaExtensionKey, err)
+            }
+        }
+        // Files are mounted from a config map volume, next to any mounts set through x-avassa
+        if volume, mount, err := buildFileMount(cname, c.Files); err != nil {
+            return ApplicationSpec{}, fmt.Errorf("workload: %s: container: %s: %w", workloadName, cname, err)
+        } else if volume != nil {
+            fileVolumes = append(fileVolumes, volume)
+            ac.Mounts = append(ac.Mounts, mount)
+        }
+        svc.Containers = append(svc.Containers, ac)
+    }
+
+    // The service key of the workload extension targets the generated service, everything else the application.
+    appExt := maps.Clone(extras.Avassa)
+    if rawSvcExt, ok := appExt[avassaServiceExtensionKey]; ok {
+        delete(appExt, avassaServiceExtensionKey)
+        svcExt, ok := rawSvcExt.(map[string]interface{})
+        if !ok {
+            return ApplicationSpec{}, fmt.Errorf("workload: %s: %s: %s: expected a map", workloadName, avassaExtensionKey, avassaServiceExtensionKey)
+        }
+        if err := mergeAvassaExtension(&svc, svcExt); err != nil {
+            return ApplicationSpec{}, fmt.Errorf("workload: %s: %s: %s: %w", workloadName, avassaExtensionKey, avassaServiceExtensionKey, err)
+        }
+    }
+    svc.Volumes = append(svc.Volumes, fileVolumes...)
+    if err := checkVolumeNames(svc); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    if err := resolvePlacementRefs(svc.Placement, serviceRefs); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    if err := applyServiceMode(&svc, annotations); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    if err := validateDelayedShutdown(svc); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    app.Services = []Service{svc}
+    if len(appExt) > 0 {
+        if err := mergeAvassaExtension(&app, appExt); err != nil {
+            return ApplicationSpec{}, fmt.Errorf("workload: %s: %s: %w", workloadName, avassaExtensionKey, err)
+        }
+    }
+    if err := validateUpgradeFrom(app.UpgradeFrom); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    if err := checkServiceNames(app); err != nil {
+        return ApplicationSpec{}, fmt.Errorf("workload: %s: %w", workloadName, err)
+    }
+    return app, nil
+}
+
+// workloadAnnotations returns the metadata.annotations of the workload, or an empty map.
+func workloadAnnotations(metadata map[string]interface{}) map[string]interface{} {
+    if rawAnn, ok := metadata["annotations"].(map[string]interface{}); ok {
+        return rawAnn
+    }
+    return map[string]interface{}{}
+}
+
+// applicationName returns the Avassa application name for the workload.
+func applicationName(metadata map[string]interface{}, workloadName string) string {
+    if appName := sanitizeName(asString(metadata["name"])); appName != "" {
+        return truncateName(appName)
+    }
+    return truncateName(sanitizeName(workloadName))
+}
+
+// serviceName returns the name of the single service generated for an application.
+func serviceName(appName string) string {
+    return truncateName(fmt.Sprintf("%s-service", appName))
+}
+
+var validNameRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9\-]*[a-z0-9])?$`)
+
+func sanitizeName(in string) string {
+    in = strings.TrimSpace(strings.ToLower(in))
+    if in == "" {
+        return in
+    }
+    // replace invalid chars with '-'
+    out := make([]rune, 0, len(in))
+    for _, r := range in {
+        if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+            out = append(out, r)
+        } else {
+            out = append(out, '-')
+        }
+    }
+    s := strings.Trim(outStr(out), "-")
+    if s == "" {
+        return "app"
+    }
+    if !validNameRe.MatchString(s) {
+        // collapse multiple dashes
+        s = strings.Trim(strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == '-' }), "-"), "-")
+        if s == "" {
+            s = "app"
+        }
+    }
+    return s
+}
+
+func outStr(r []rune) string { return string(r) }
+
+func asString(v interface{}) string {
+    switch t := v.(type) {
+    case string:
+        return t
+    case fmt.Stringer:
+        return t.String()
+    case int:
+        return strconv.Itoa(t)
+    case int64:
+        return strconv.FormatInt(t, 10)
+    case float64:
+        // JSON numbers become float64; keep integers clean
+        if t == float64(int64(t)) {
+            return strconv.FormatInt(int64(t), 10)
+        }
+        return strconv.FormatFloat(t, 'f', -1, 64)
+    case bool:
+        if t { return "true" }
+        return "false"
+    default:
+        return ""
+    }
+}
+
+func asInt(v interface{}, def int) int {
+    switch t := v.(type) {
+    case int:
+        return t
+    case int64:
+        return int(t)
+    case float64:
+        return int(t)
+    case string:
+        if i, err := strconv.Atoi(strings.TrimSpace(t)); err == nil { return i }
+        return def
+    default:
+        return def
+    }
+}
+
+func asBool(v interface{}, def bool) bool {
+    switch t := v.(type) {
+    case bool:
+        return t
+    case string:
+        if t == "true" { return true }
+        if t == "false" { return false }
+        return def
+    default:
+        return def
+    }
+}
+
+// containerAnnotation returns the per-container annotation avassa.containers.<container>.<key> if it is set, otherwise
+// the workload wide annotation avassa.<key>.
+func containerAnnotation(annotations map[string]interface{}, containerName string, key string) (interface{}, bool) {
+    if v, ok := annotations["avassa.containers."+containerName+"."+key]; ok {
+        return v, true
+    }
+    v, ok := annotations["avassa."+key]
+    return v, ok
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if strings.TrimSpace(v) != "" {
+            return v
+        }
+    }
+    return ""
+}
+
+// mapScoreProbeToAvassa converts a Score probe (HTTP or Exec) to an Avassa probe spec.
+// If both Exec and HTTP are present, Exec is preferred.
+func mapScoreProbeToAvassa(p *scoretypes.ContainerProbe) *Probe {
+    if p == nil { return nil }
+    out := &Probe{}
+    if p.Exec != nil && len(p.Exec.Command) > 0 {
+        out.Exec = &ExecProbe{Cmd: append([]string{}, p.Exec.Command...)}
+        return out
+    }
+    if p.HttpGet != nil {
+        http := &HTTPProbe{Path: p.HttpGet.Path, Port: p.HttpGet.Port}
+        if p.HttpGet.Scheme != nil {
+            // Score scheme is enum HTTP|HTTPS; Avassa expects lowercase http|https
+            http.Scheme = strings.ToLower(string(*p.HttpGet.Scheme))
+        }
+        if p.HttpGet.Host != nil {
+            http.Host = *p.HttpGet.Host
+        }
+        if len(p.HttpGet.HttpHeaders) > 0 {
+            headers := make(map[string]string, len(p.HttpGet.HttpHeaders))
+            for _, h := range p.HttpGet.HttpHeaders {
+                name := strings.TrimSpace(h.Name)
+                val := strings.TrimSpace(h.Value)
+                if name == "" { continue }
+                if prev, ok := headers[name]; ok && prev != "" {
+                    headers[name] = prev + ", " + val
+                } else {
+                    headers[name] = val
+                }
+            }
+            if len(headers) > 0 {
+                http.RequestHeaders = headers
+            }
+        }
+        out.HTTP = http
+        return out
+    }
+    return nil
+}